@@ -0,0 +1,44 @@
+package secret
+
+import "time"
+
+const (
+	// checkRetryInterval is the delay before an advisory IAM check that could not be made
+	// is tried again with the same inputs. It doubles after each failure, up to
+	// checkRetryMaxInterval.
+	checkRetryInterval    = time.Minute
+	checkRetryMaxInterval = time.Hour
+)
+
+// checkBackoff delays the retries of an advisory check that could not be made, so that an
+// IAM outage is not hit, nor reported in an event, on every sync. The delay starts again
+// when the inputs of the check, identified by their hash, change.
+type checkBackoff struct {
+	hash     string
+	failures int
+	retryAt  time.Time
+}
+
+// waiting returns whether the check of hash failed and must not be retried before now.
+func (b *checkBackoff) waiting(hash string, now time.Time) bool {
+	return b.hash == hash && now.Before(b.retryAt)
+}
+
+// failed records that the check of hash failed at now and returns when it is retried.
+func (b *checkBackoff) failed(hash string, now time.Time) time.Time {
+	if b.hash != hash {
+		*b = checkBackoff{hash: hash}
+	}
+	delay := checkRetryMaxInterval
+	if b.failures < 6 {
+		delay = min(checkRetryInterval<<b.failures, checkRetryMaxInterval)
+	}
+	b.failures++
+	b.retryAt = now.Add(delay)
+	return b.retryAt
+}
+
+// reset forgets the failures, after the check could be made.
+func (b *checkBackoff) reset() {
+	*b = checkBackoff{}
+}
@@ -0,0 +1,291 @@
+package secret
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"gopkg.in/gcfg.v1"
+)
+
+const (
+	// providerSection is the cloud.conf INI section holding the IBM Cloud provider settings.
+	providerSection = "provider"
+	// regionKey is the key in the provider section with the region of the cluster.
+	regionKey = "region"
+	// resourceGroupNameKey is the key in the provider section with the name of the cluster resource group.
+	resourceGroupNameKey = "g2ResourceGroupName"
+	// resourceGroupIDKey is the optional key in the provider section with the ID of the cluster resource group.
+	resourceGroupIDKey = "g2ResourceGroupID"
+	// accountIDKey is the key in the provider section with the IBM Cloud account ID.
+	accountIDKey = "accountID"
+)
+
+var resourceGroupIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// CloudConfig is the typed content of cloud.conf, as rendered by the cloud controller
+// manager operator into the cloud-conf ConfigMap. Only the fields used by the operator
+// are listed, all other sections and keys are ignored.
+type CloudConfig struct {
+	Provider ProviderConfig `gcfg:"provider"`
+}
+
+// ProviderConfig holds the [provider] section of cloud.conf.
+type ProviderConfig struct {
+	AccountID           string `gcfg:"accountID"`
+	Region              string `gcfg:"region"`
+	G2ResourceGroupName string `gcfg:"g2ResourceGroupName"`
+	G2ResourceGroupID   string `gcfg:"g2ResourceGroupID"`
+	IAMEndpointOverride string `gcfg:"iamEndpointOverride"`
+	G2EndpointOverride  string `gcfg:"g2EndpointOverride"`
+	RMEndpointOverride  string `gcfg:"rmEndpointOverride"`
+}
+
+// CloudConfigFieldError describes an invalid or missing key in cloud.conf.
+type CloudConfigFieldError struct {
+	Section string
+	Key     string
+	Reason  string
+}
+
+func (e *CloudConfigFieldError) Error() string {
+	return fmt.Sprintf("%s: [%s] %s %s", CloudConfigmapKey, e.Section, e.Key, e.Reason)
+}
+
+// ParseCloudConfig parses the cloud.conf document. Unknown sections and keys are
+// tolerated, syntax errors are not. Keys before the first section header belong to
+// the provider section, as in the flat layout read by earlier versions of the operator.
+// The result must be checked with Validate once all other sources of the configuration
+// have been applied.
+func ParseCloudConfig(data string) (*CloudConfig, error) {
+	cfg := &CloudConfig{}
+	if !startsWithSection(data) {
+		data = "[" + providerSection + "]\n" + data
+	}
+	if err := gcfg.FatalOnly(gcfg.ReadStringInto(cfg, data)); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", CloudConfigmapKey, err)
+	}
+	cfg.Provider.trimSpace()
+	return cfg, nil
+}
+
+// startsWithSection returns whether the first line of data that is neither blank nor a
+// comment is a section header.
+func startsWithSection(data string) bool {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		return strings.HasPrefix(line, "[")
+	}
+	return false
+}
+
+// ClusterCloudConfig returns cloud.conf from cloud-conf with the Infrastructure status applied,
+// for the controllers that need the location or the account of the cluster. Either may
+// not exist yet, the result is then empty or taken from the one that exists. The result
+// is not validated.
+func ClusterCloudConfig(configMapLister corelisters.ConfigMapLister, infraLister configlisters.InfrastructureLister) (*CloudConfig, error) {
+	cloudConfig := &CloudConfig{}
+	cloudConf, err := configMapLister.ConfigMaps(util.ConfigMapNamespace).Get(util.ConfigMapName)
+	if err == nil {
+		if cloudConfig, err = ParseCloudConfig(cloudConf.Data[CloudConfigmapKey]); err != nil {
+			return nil, fmt.Errorf("cloud-credential-operator configmap %s is invalid: %w", util.ConfigMapName, err)
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	infra, err := infraLister.Get(infraConfigName)
+	if err == nil {
+		cloudConfig.ApplyInfrastructure(infra)
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return cloudConfig, nil
+}
+
+// EffectiveCloudConfig returns ClusterCloudConfig with the overrides used by the SecretSync
+// controller applied: the credentials override secret and the resource group ID of the
+// operator config ConfigMap. The result is not validated.
+func EffectiveCloudConfig(
+	configMapLister corelisters.ConfigMapLister,
+	operatorConfigMapLister corelisters.ConfigMapLister,
+	infraLister configlisters.InfrastructureLister,
+	secretLister corelisters.SecretLister) (*CloudConfig, error) {
+	cloudConfig, err := ClusterCloudConfig(configMapLister, infraLister)
+	if err != nil {
+		return nil, err
+	}
+	credentialsSecret, err := GetCredentialsSecret(secretLister)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		credentialsSecret = nil
+	}
+	operatorConfig, err := operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		operatorConfig = nil
+	}
+	if err := cloudConfig.applyOverrides(credentialsSecret, operatorConfig); err != nil {
+		return nil, err
+	}
+	return cloudConfig, nil
+}
+
+// applyOverrides applies credentialsSecret, when it is the credentials override secret, and
+// the resource group ID of operatorConfig. Both may be nil.
+func (c *CloudConfig) applyOverrides(credentialsSecret *v1.Secret, operatorConfig *v1.ConfigMap) error {
+	if isCredentialsOverride(credentialsSecret) {
+		c.ApplyCredentialsOverride(credentialsSecret)
+	}
+	resourceGroupIDOverride, err := resourceGroupIDOverride(operatorConfig)
+	if err != nil {
+		return err
+	}
+	if resourceGroupIDOverride != "" {
+		c.Provider.G2ResourceGroupID = resourceGroupIDOverride
+	}
+	return nil
+}
+
+// ApplyInfrastructure overrides the values of cloud.conf with the ones published in
+// Infrastructure.status.platformStatus.ibmcloud, which is the authoritative source
+// of the cluster location, resource group and service endpoints. Values missing
+// in the Infrastructure are kept from cloud.conf. A cloud.conf resource group ID
+// is dropped when the Infrastructure names another resource group.
+func (c *CloudConfig) ApplyInfrastructure(infra *configv1.Infrastructure) {
+	if infra == nil || infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.IBMCloud == nil {
+		return
+	}
+	status := infra.Status.PlatformStatus.IBMCloud
+	if status.Location != "" {
+		c.Provider.Region = status.Location
+	}
+	if status.ResourceGroupName != "" && status.ResourceGroupName != c.Provider.G2ResourceGroupName {
+		c.Provider.G2ResourceGroupName = status.ResourceGroupName
+		c.Provider.G2ResourceGroupID = ""
+	}
+	for _, endpoint := range status.ServiceEndpoints {
+		if endpoint.URL == "" {
+			continue
+		}
+		switch endpoint.Name {
+		case configv1.IBMCloudServiceIAM:
+			c.Provider.IAMEndpointOverride = endpoint.URL
+		case configv1.IBMCloudServiceVPC:
+			c.Provider.G2EndpointOverride = endpoint.URL
+		case configv1.IBMCloudServiceResourceManager:
+			c.Provider.RMEndpointOverride = endpoint.URL
+		}
+	}
+}
+
+// ApplyCredentialsOverride overrides the account, resource group and endpoints with the ones
+// in the credentials override secret, whose keys are named like the cloud.conf ones. The
+// service ID of the override may belong to another account, so a resource group given
+// only by name or only by ID replaces both the name and the ID of cloud.conf.
+func (c *CloudConfig) ApplyCredentialsOverride(secret *v1.Secret) {
+	value := func(key string) string {
+		return strings.TrimSpace(string(secret.Data[key]))
+	}
+	if accountID := value(accountIDKey); accountID != "" {
+		c.Provider.AccountID = accountID
+	}
+	if name, id := value(resourceGroupNameKey), value(resourceGroupIDKey); name != "" || id != "" {
+		c.Provider.G2ResourceGroupName = name
+		c.Provider.G2ResourceGroupID = id
+	}
+	for key, field := range map[string]*string{
+		iamEndpointOverride: &c.Provider.IAMEndpointOverride,
+		g2EndpointOverride:  &c.Provider.G2EndpointOverride,
+		rmEndpointOverride:  &c.Provider.RMEndpointOverride,
+	} {
+		if endpoint := value(key); endpoint != "" {
+			*field = endpoint
+		}
+	}
+}
+
+// Validate checks that all keys required by the operator are set and that the
+// resource group ID and endpoint overrides, if provided, are well formed.
+func (c *CloudConfig) Validate() error {
+	var errs []error
+	required := []struct {
+		key   string
+		value string
+	}{
+		{regionKey, c.Provider.Region},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			errs = append(errs, &CloudConfigFieldError{Section: providerSection, Key: r.key, Reason: "is required"})
+		}
+	}
+
+	// The account and the resource group name are only needed to look up the resource group ID.
+	if c.Provider.G2ResourceGroupID == "" {
+		if c.Provider.AccountID == "" {
+			errs = append(errs, &CloudConfigFieldError{Section: providerSection, Key: accountIDKey, Reason: fmt.Sprintf("is required when %s is not set", resourceGroupIDKey)})
+		}
+		if c.Provider.G2ResourceGroupName == "" {
+			errs = append(errs, &CloudConfigFieldError{Section: providerSection, Key: resourceGroupNameKey, Reason: fmt.Sprintf("is required when %s is not set", resourceGroupIDKey)})
+		}
+	} else if err := validateResourceGroupID(c.Provider.G2ResourceGroupID); err != nil {
+		errs = append(errs, &CloudConfigFieldError{Section: providerSection, Key: resourceGroupIDKey, Reason: err.Error()})
+	}
+
+	endpoints := []struct {
+		key   string
+		value string
+	}{
+		{iamEndpointOverride, c.Provider.IAMEndpointOverride},
+		{g2EndpointOverride, c.Provider.G2EndpointOverride},
+		{rmEndpointOverride, c.Provider.RMEndpointOverride},
+	}
+	for _, e := range endpoints {
+		if e.value == "" {
+			continue
+		}
+		if u, err := url.Parse(e.value); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, &CloudConfigFieldError{Section: providerSection, Key: e.key, Reason: fmt.Sprintf("is not an absolute URL: %q", e.value)})
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateResourceGroupID checks that id looks like an IBM Cloud resource group ID,
+// i.e. 32 lowercase hexadecimal characters.
+func validateResourceGroupID(id string) error {
+	if !resourceGroupIDRegexp.MatchString(id) {
+		return fmt.Errorf("is not a valid resource group ID: %q", id)
+	}
+	return nil
+}
+
+func (p *ProviderConfig) trimSpace() {
+	for _, s := range []*string{
+		&p.AccountID,
+		&p.Region,
+		&p.G2ResourceGroupName,
+		&p.G2ResourceGroupID,
+		&p.IAMEndpointOverride,
+		&p.G2EndpointOverride,
+		&p.RMEndpointOverride,
+	} {
+		*s = strings.TrimSpace(*s)
+	}
+}
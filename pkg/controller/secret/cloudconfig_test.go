@@ -0,0 +1,70 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCloudConfigErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		conf           string
+		expectedErrors []string
+	}{
+		{
+			name:           "unterminated section header",
+			conf:           "[provider\nregion = us-south\n",
+			expectedErrors: []string{"failed to parse cloud.conf"},
+		},
+		{
+			name:           "unterminated quote",
+			conf:           "[provider]\nregion = \"us-south\n",
+			expectedErrors: []string{"failed to parse cloud.conf"},
+		},
+		{
+			name: "keys in the wrong section",
+			conf: "[global]\nregion = us-south\ng2ResourceGroupName = mycluster-rg\naccountID = testaccount\n[provider]\n",
+			expectedErrors: []string{
+				"cloud.conf: [provider] region is required",
+				"cloud.conf: [provider] g2ResourceGroupName is required when g2ResourceGroupID is not set",
+				"cloud.conf: [provider] accountID is required when g2ResourceGroupID is not set",
+			},
+		},
+		{
+			name:           "blank quoted value",
+			conf:           "[provider]\nregion = \"  \"\ng2ResourceGroupName = mycluster-rg\naccountID = testaccount\n",
+			expectedErrors: []string{"cloud.conf: [provider] region is required"},
+		},
+		{
+			name: "invalid resource group ID",
+			conf: "[provider]\nregion = us-south\ng2ResourceGroupID = mycluster-rg\naccountID = testaccount\n",
+			expectedErrors: []string{
+				`cloud.conf: [provider] g2ResourceGroupID is not a valid resource group ID: "mycluster-rg"`,
+			},
+		},
+		{
+			name: "relative endpoint override",
+			conf: "[provider]\nregion = us-south\ng2ResourceGroupName = mycluster-rg\naccountID = testaccount\niamEndpointOverride = private.iam.cloud.ibm.com\n",
+			expectedErrors: []string{
+				`cloud.conf: [provider] iamEndpointOverride is not an absolute URL: "private.iam.cloud.ibm.com"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseCloudConfig(tt.conf)
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if err == nil {
+				t.Fatalf("ParseCloudConfig() expected error, got config %+v", cfg)
+			}
+			for _, expected := range tt.expectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("ParseCloudConfig() error %q does not contain %q", err, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// credentialsDegradedConditionType is set to True when IAM rejects the API key
+	// provided by cloud-credential-operator.
+	credentialsDegradedConditionType = "CredentialsDegraded"
+)
+
+// CredentialsError is returned when IAM refuses to exchange the API key for a token.
+type CredentialsError struct {
+	StatusCode    int
+	ErrorCode     string
+	TransactionID string
+	Message       string
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("IAM rejected the API key with status %d, error code %q, transaction ID %q: %s", e.StatusCode, e.ErrorCode, e.TransactionID, e.Message)
+}
+
+// defaultValidateAPIKey exchanges apiKey for an IAM token at iamEndpoint using client. A key
+// rejected by IAM with status 400 or 401 is reported as *CredentialsError, any other failure
+// (network, IAM outage, rate limiting) as a plain error.
+func defaultValidateAPIKey(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error {
+	_, err := ibmcloud.RequestIAMToken(ctx, client, apiKey, iamEndpoint)
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *ibmcloud.APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnauthorized) {
+		return fmt.Errorf("failed to validate the API key at %s: %w", iamEndpoint, err)
+	}
+	credentialsErr := &CredentialsError{
+		StatusCode:    apiErr.StatusCode,
+		TransactionID: apiErr.TransactionID,
+		Message:       apiErr.Err.Error(),
+	}
+	var tokenErr *ibmcloud.IAMTokenError
+	if errors.As(err, &tokenErr) {
+		credentialsErr.ErrorCode = tokenErr.ErrorCode
+		credentialsErr.Message = tokenErr.Message
+	}
+	return credentialsErr
+}
+
+// validateCredentials checks apiKey against IAM. Once a key has been accepted, it is
+// not checked again until the key or the IAM endpoint changes. Only a key rejected by IAM
+// is an error: when IAM cannot be reached, the key is not known to be invalid, so it is
+// used and checked again on the next sync.
+func (c *SecretSyncController) validateCredentials(ctx context.Context, apiKey, iamEndpoint string) error {
+	hash := sha256.Sum256([]byte(iamEndpoint + "\n" + apiKey))
+	credentialsHash := hex.EncodeToString(hash[:])
+	if credentialsHash == c.validatedCredentialsHash {
+		return nil
+	}
+	client, err := c.httpClients.Get()
+	if err != nil {
+		return err
+	}
+	err = c.validateAPIKey(ctx, client, apiKey, iamEndpoint)
+	var credentialsErr *CredentialsError
+	switch {
+	case errors.As(err, &credentialsErr):
+		return err
+	case err != nil:
+		klog.V(2).ErrorS(err, "Error while validating the API key, using it unvalidated")
+		return nil
+	}
+	klog.V(2).Infof("API key accepted by %s", iamEndpoint)
+	c.validatedCredentialsHash = credentialsHash
+	return nil
+}
+
+// updateCredentialsCondition sets the CredentialsDegraded condition from the result of
+// translateSecret for credentialsSecret. Errors unrelated to the credentials leave the
+// condition unchanged.
+func (c *SecretSyncController) updateCredentialsCondition(ctx context.Context, credentialsSecret *v1.Secret, translateErr error) error {
+	condition := operatorv1.OperatorCondition{
+		Type:   credentialsDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if translateErr != nil {
+		var credentialsErr *CredentialsError
+		if !errors.As(translateErr, &credentialsErr) {
+			return nil
+		}
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "APIKeyRejected"
+		condition.Message = fmt.Sprintf("The API key in secret %s/%s was rejected, keeping the last valid %s: %s",
+			credentialsSecret.Namespace, credentialsSecret.Name, util.IBMCSIDriverSecretName, credentialsErr.Error())
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(condition))
+	return err
+}
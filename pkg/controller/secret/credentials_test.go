@@ -0,0 +1,219 @@
+package secret
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaultValidateAPIKey(t *testing.T) {
+	tests := []struct {
+		name                  string
+		statusCode            int
+		body                  string
+		expectError           bool
+		expectedCredentialErr *CredentialsError
+	}{
+		{
+			name:       "valid key",
+			statusCode: http.StatusOK,
+			body:       `{"access_token":"token","refresh_token":"not_supported","token_type":"Bearer","expires_in":3600,"expiration":1700000000}`,
+		},
+		{
+			name:        "key not found",
+			statusCode:  http.StatusBadRequest,
+			body:        `{"errorCode":"BXNIM0415E","errorMessage":"Provided API key could not be found.","context":{"transactionId":"txn-1234"}}`,
+			expectError: true,
+			expectedCredentialErr: &CredentialsError{
+				StatusCode:    http.StatusBadRequest,
+				ErrorCode:     "BXNIM0415E",
+				TransactionID: "txn-1234",
+				Message:       "Provided API key could not be found.",
+			},
+		},
+		{
+			name:        "key locked",
+			statusCode:  http.StatusUnauthorized,
+			body:        `{"errorCode":"BXNIM0602E","errorMessage":"The API key is locked.","context":{"transactionId":"txn-1234"}}`,
+			expectError: true,
+			expectedCredentialErr: &CredentialsError{
+				StatusCode:    http.StatusUnauthorized,
+				ErrorCode:     "BXNIM0602E",
+				TransactionID: "txn-1234",
+				Message:       "The API key is locked.",
+			},
+		},
+		{
+			name:        "IAM outage is not a credentials error",
+			statusCode:  http.StatusServiceUnavailable,
+			body:        `{"errorCode":"BXNIM0001E","errorMessage":"Service unavailable."}`,
+			expectError: true,
+		},
+		{
+			name:        "forbidden is not a credentials error",
+			statusCode:  http.StatusForbidden,
+			body:        `{"errorCode":"BXNIM0403E","errorMessage":"Forbidden."}`,
+			expectError: true,
+		},
+		{
+			name:        "rate limiting is not a credentials error",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `{"errorCode":"BXNIM0429E","errorMessage":"Too many requests."}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/identity/token" {
+					t.Errorf("unexpected request path %s", r.URL.Path)
+				}
+				if err := r.ParseForm(); err != nil || r.Form.Get("apikey") != "testapikey" {
+					t.Errorf("unexpected API key in request: %v", r.Form)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(ibmcloud.TransactionIDHeader, "txn-1234")
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			// The IAM stand-in is only trusted through the CA bundle given to the client.
+			client, err := ibmcloud.NewHTTPClient(nil, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+			if err != nil {
+				t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+			}
+			err = defaultValidateAPIKey(context.TODO(), client, "testapikey", server.URL)
+			if err == nil {
+				if tt.expectError {
+					t.Fatalf("defaultValidateAPIKey() expected error, got none")
+				}
+				return
+			}
+			if !tt.expectError {
+				t.Fatalf("defaultValidateAPIKey() unexpected error: %v", err)
+			}
+			var credentialsErr *CredentialsError
+			isCredentialsErr := errors.As(err, &credentialsErr)
+			if tt.expectedCredentialErr == nil {
+				if isCredentialsErr {
+					t.Errorf("defaultValidateAPIKey() returned credentials error %v, expected a plain error", err)
+				}
+				return
+			}
+			if !isCredentialsErr {
+				t.Fatalf("defaultValidateAPIKey() returned %v, expected a credentials error", err)
+			}
+			if *credentialsErr != *tt.expectedCredentialErr {
+				t.Errorf("defaultValidateAPIKey() got %+v, expected %+v", *credentialsErr, *tt.expectedCredentialErr)
+			}
+		})
+	}
+}
+
+func TestSyncRejectedAPIKey(t *testing.T) {
+	lastValidSecret := &k8v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.IBMCSIDriverSecretName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string][]byte{StorageSecretStoreKey: []byte("last valid")},
+	}
+	cloudSecret := newCloudSecret("revokedkey")
+	cloudConf := newCloudConf(testCloudConf)
+
+	c := newTestSecretSyncController(fakeGetResourceID, lastValidSecret, cloudSecret, cloudConf)
+	rejected := &CredentialsError{StatusCode: http.StatusBadRequest, ErrorCode: "BXNIM0415E", TransactionID: "txn-1234", Message: "Provided API key could not be found."}
+	c.validateAPIKey = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error {
+		if apiKey == "revokedkey" {
+			return rejected
+		}
+		return nil
+	}
+
+	syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+	if err := c.sync(context.TODO(), syncCtx); err == nil {
+		t.Fatalf("sync() expected error for a rejected API key")
+	}
+	secret, err := c.kubeClient.CoreV1().Secrets(util.OperatorNamespace).Get(context.TODO(), util.IBMCSIDriverSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", util.IBMCSIDriverSecretName, err)
+	}
+	if string(secret.Data[StorageSecretStoreKey]) != "last valid" {
+		t.Errorf("sync() replaced the last valid secret with %q", secret.Data[StorageSecretStoreKey])
+	}
+	_, status, _, _ := c.operatorClient.GetOperatorState()
+	condition := v1helpers.FindOperatorCondition(status.Conditions, credentialsDegradedConditionType)
+	if condition == nil || condition.Status != operatorv1.ConditionTrue {
+		t.Fatalf("sync() expected %s=True, got %+v", credentialsDegradedConditionType, condition)
+	}
+	if expected := rejected.Error(); !strings.Contains(condition.Message, expected) {
+		t.Errorf("condition message %q does not contain %q", condition.Message, expected)
+	}
+
+	// A fixed key is published and clears the condition.
+	cloudSecret.Data[cloudSecretKey] = []byte("validkey")
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	secret, err = c.kubeClient.CoreV1().Secrets(util.OperatorNamespace).Get(context.TODO(), util.IBMCSIDriverSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", util.IBMCSIDriverSecretName, err)
+	}
+	if string(secret.Data[StorageSecretStoreKey]) == "last valid" {
+		t.Errorf("sync() did not publish the secret with the valid API key")
+	}
+	_, status, _, _ = c.operatorClient.GetOperatorState()
+	if !v1helpers.IsOperatorConditionFalse(status.Conditions, credentialsDegradedConditionType) {
+		t.Errorf("sync() expected %s=False, got %+v", credentialsDegradedConditionType, status.Conditions)
+	}
+}
+
+func TestSyncIAMUnreachable(t *testing.T) {
+	cloudSecret := newCloudSecret("validkey")
+	cloudConf := newCloudConf(testCloudConf)
+
+	c := newTestSecretSyncController(fakeGetResourceID, cloudSecret, cloudConf)
+	calls := 0
+	c.validateAPIKey = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error {
+		calls++
+		return errors.New("dial tcp: i/o timeout")
+	}
+
+	syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	secret, err := c.kubeClient.CoreV1().Secrets(util.OperatorNamespace).Get(context.TODO(), util.IBMCSIDriverSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", util.IBMCSIDriverSecretName, err)
+	}
+	if !strings.Contains(string(secret.Data[StorageSecretStoreKey]), "validkey") {
+		t.Errorf("sync() did not publish the API key, got %q", secret.Data[StorageSecretStoreKey])
+	}
+	_, status, _, _ := c.operatorClient.GetOperatorState()
+	if v1helpers.IsOperatorConditionTrue(status.Conditions, credentialsDegradedConditionType) {
+		t.Errorf("sync() expected %s not True when IAM cannot be reached, got %+v", credentialsDegradedConditionType, status.Conditions)
+	}
+
+	// The key is not known to be valid, it is checked again on the next sync.
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the API key to be validated on each sync while IAM cannot be reached, got %d calls", calls)
+	}
+}
@@ -0,0 +1,36 @@
+package secret
+
+import (
+	"fmt"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Name of the cluster-scoped CloudCredential object with the mode of cloud-credential-operator.
+const cloudCredentialConfigName = "cluster"
+
+// credentialsModeManual returns true when cloud-credential-operator runs in Manual mode, in
+// which it does not provision the credentials secret and the admin must create it.
+func (c *SecretSyncController) credentialsModeManual() (bool, error) {
+	cloudCredential, err := c.cloudCredentialLister.Get(cloudCredentialConfigName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return cloudCredential.Spec.CredentialsMode == operatorv1.CloudCredentialsModeManual, nil
+}
+
+// manualCredentialsMessage lists the keys the admin must provide when cloud-credential-operator
+// runs in Manual mode.
+func manualCredentialsMessage() string {
+	return fmt.Sprintf("cloud-credential-operator runs in Manual mode and does not provision secret %s/%s. "+
+		"Create it with key %s holding the API key of a service ID with the roles of CredentialsRequest %s/%s, "+
+		"or with key %s holding the ID of an IAM trusted profile, or create secret %s/%s with key %s",
+		util.OperatorNamespace, util.CloudCredentialSecretName,
+		cloudSecretKey, util.CredentialsRequestNamespace, util.CredentialsRequestName,
+		trustedProfileSecretKey, util.OperatorNamespace, util.CredentialsOverrideSecretName, cloudSecretKey)
+}
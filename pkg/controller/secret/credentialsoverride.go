@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// credentialsSourceConditionType reports which secret provides the credentials of the driver.
+const credentialsSourceConditionType = "CredentialsSourceAvailable"
+
+// isCredentialsOverride returns true when secret is the admin-supplied credentials override secret.
+func isCredentialsOverride(secret *v1.Secret) bool {
+	return secret != nil && secret.Namespace == util.OperatorNamespace && secret.Name == util.CredentialsOverrideSecretName
+}
+
+// GetCredentialsSecret returns the secret with the credentials of the driver: the credentials
+// override secret when it exists, the cloud-credential-operator secret otherwise. A NotFound
+// error is returned for the cloud-credential-operator secret when neither exists.
+func GetCredentialsSecret(secretLister corelisters.SecretLister) (*v1.Secret, error) {
+	overrideSecret, err := secretLister.Secrets(util.OperatorNamespace).Get(util.CredentialsOverrideSecretName)
+	if err == nil {
+		return overrideSecret, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return secretLister.Secrets(util.OperatorNamespace).Get(util.CloudCredentialSecretName)
+}
+
+// updateCredentialsSourceCondition reports credentialsSecret as the source of the credentials.
+func (c *SecretSyncController) updateCredentialsSourceCondition(ctx context.Context, credentialsSecret *v1.Secret) error {
+	condition := operatorv1.OperatorCondition{
+		Type:    credentialsSourceConditionType,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  "CloudCredentialOperator",
+		Message: fmt.Sprintf("Using the credentials from secret %s/%s provided by cloud-credential-operator", credentialsSecret.Namespace, credentialsSecret.Name),
+	}
+	if isCredentialsOverride(credentialsSecret) {
+		condition.Reason = "CredentialsOverride"
+		condition.Message = fmt.Sprintf("Using the credentials from override secret %s/%s instead of %s", credentialsSecret.Namespace, credentialsSecret.Name, util.CloudCredentialSecretName)
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(condition))
+	return err
+}
@@ -0,0 +1,110 @@
+package secret
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSyncCredentialsOverride(t *testing.T) {
+	cloudSecret := newCloudSecret("ccoapikey")
+	cloudConf := newCloudConf("[provider]\naccountID = clusteraccount\nregion = us-south\ng2ResourceGroupName = clusterresource\ng2ResourceGroupID = 0123456789abcdef0123456789abcdef\n")
+
+	tests := []struct {
+		name               string
+		overrideData       map[string][]byte
+		withoutCloudSecret bool
+		expectedConfig     *DriverConfig
+		expectedLookup     []string
+		expectedReason     string
+	}{
+		{
+			name:           "no override",
+			expectedConfig: newAPIKeyDriverConfig(defaultTokenExchangeURL, "https://us-south.iaas.cloud.ibm.com", "0123456789abcdef0123456789abcdef", "ccoapikey"),
+			expectedReason: "CloudCredentialOperator",
+		},
+		{
+			name:           "override with API key only",
+			overrideData:   map[string][]byte{cloudSecretKey: []byte("overrideapikey")},
+			expectedConfig: newAPIKeyDriverConfig(defaultTokenExchangeURL, "https://us-south.iaas.cloud.ibm.com", "0123456789abcdef0123456789abcdef", "overrideapikey"),
+			expectedReason: "CredentialsOverride",
+		},
+		{
+			name: "override with account, resource group and endpoints",
+			overrideData: map[string][]byte{
+				cloudSecretKey:       []byte("overrideapikey"),
+				accountIDKey:         []byte("billingaccount"),
+				resourceGroupNameKey: []byte("billingresource"),
+				iamEndpointOverride:  []byte("https://private.iam.cloud.ibm.com"),
+				g2EndpointOverride:   []byte("https://us-south.private.iaas.cloud.ibm.com"),
+			},
+			expectedConfig: newAPIKeyDriverConfig("https://private.iam.cloud.ibm.com", "https://us-south.private.iaas.cloud.ibm.com", "fakeid", "overrideapikey"),
+			expectedLookup: []string{"billingresource", "billingaccount", "overrideapikey"},
+			expectedReason: "CredentialsOverride",
+		},
+		{
+			name:               "override without the cloud-credential-operator secret",
+			overrideData:       map[string][]byte{cloudSecretKey: []byte("overrideapikey"), resourceGroupIDKey: []byte("fedcba9876543210fedcba9876543210")},
+			withoutCloudSecret: true,
+			expectedConfig:     newAPIKeyDriverConfig(defaultTokenExchangeURL, "https://us-south.iaas.cloud.ibm.com", "fedcba9876543210fedcba9876543210", "overrideapikey"),
+			expectedReason:     "CredentialsOverride",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{cloudConf}
+			if !tt.withoutCloudSecret {
+				objects = append(objects, cloudSecret)
+			}
+			if tt.overrideData != nil {
+				objects = append(objects, &k8v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      util.CredentialsOverrideSecretName,
+						Namespace: util.OperatorNamespace,
+					},
+					Data: tt.overrideData,
+				})
+			}
+			var lookup []string
+			c := newTestSecretSyncController(func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+				lookup = []string{resourceName, accountID, apiKey}
+				return "fakeid", "", nil
+			}, objects...)
+
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			secret, err := c.kubeClient.CoreV1().Secrets(util.OperatorNamespace).Get(context.TODO(), util.IBMCSIDriverSecretName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", util.IBMCSIDriverSecretName, err)
+			}
+			if actual, expected := string(secret.Data[StorageSecretStoreKey]), driverConfigToml(tt.expectedConfig); actual != expected {
+				t.Errorf("sync() published:\n%s\nexpected:\n%s", actual, expected)
+			}
+			if len(tt.expectedLookup) > 0 && (len(lookup) == 0 || lookup[0] != tt.expectedLookup[0] || lookup[1] != tt.expectedLookup[1] || lookup[2] != tt.expectedLookup[2]) {
+				t.Errorf("sync() looked up %v, expected %v", lookup, tt.expectedLookup)
+			}
+			if len(tt.expectedLookup) == 0 && lookup != nil {
+				t.Errorf("sync() unexpectedly looked up %v", lookup)
+			}
+
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			condition := v1helpers.FindOperatorCondition(status.Conditions, credentialsSourceConditionType)
+			if condition == nil || condition.Status != operatorv1.ConditionTrue || condition.Reason != tt.expectedReason {
+				t.Errorf("sync() expected %s=True with reason %s, got %+v", credentialsSourceConditionType, tt.expectedReason, condition)
+			}
+			if v1helpers.IsOperatorConditionTrue(status.Conditions, cloudInputsProgressingConditionType) {
+				t.Errorf("sync() reported missing inputs: %+v", status.Conditions)
+			}
+		})
+	}
+}
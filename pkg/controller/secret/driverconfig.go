@@ -0,0 +1,134 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// iamClientID is the IAM client ID and secret used by the driver for the API key flow.
+	iamClientID = "bx"
+	// providerTypeG2 is the VPC generation 2 provider of the driver.
+	providerTypeG2 = "g2"
+	// redactedValue replaces credentials in the summary of configuration changes.
+	redactedValue = "<redacted>"
+)
+
+// DriverConfig is the content of slclient.toml in the storage-secret-store secret.
+type DriverConfig struct {
+	VPC VPCProviderConfig `toml:"vpc"`
+}
+
+// VPCProviderConfig is the [vpc] section of slclient.toml read by the driver.
+// Either G2APIKey or IAMProfileID is set, depending on the credentials mode.
+type VPCProviderConfig struct {
+	IAMClientID                string `toml:"iam_client_id,omitempty"`
+	IAMClientSecret            string `toml:"iam_client_secret,omitempty"`
+	G2TokenExchangeEndpointURL string `toml:"g2_token_exchange_endpoint_url"`
+	G2RIAASEndpointURL         string `toml:"g2_riaas_endpoint_url"`
+	G2ResourceGroupID          string `toml:"g2_resource_group_id"`
+	G2APIKey                   string `toml:"g2_api_key,omitempty"`
+	IAMProfileID               string `toml:"iam_profile_id,omitempty"`
+	CRTokenFilename            string `toml:"cr_token_filename,omitempty"`
+	ProviderType               string `toml:"provider_type"`
+}
+
+// newAPIKeyDriverConfig returns the driver configuration for authentication with an API key.
+func newAPIKeyDriverConfig(iamEndpoint, riaasEndpoint, resourceGroupID, apiKey string) *DriverConfig {
+	return &DriverConfig{
+		VPC: VPCProviderConfig{
+			IAMClientID:                iamClientID,
+			IAMClientSecret:            iamClientID,
+			G2TokenExchangeEndpointURL: iamEndpoint,
+			G2RIAASEndpointURL:         riaasEndpoint,
+			G2ResourceGroupID:          resourceGroupID,
+			G2APIKey:                   apiKey,
+			ProviderType:               providerTypeG2,
+		},
+	}
+}
+
+// newTrustedProfileDriverConfig returns the driver configuration for authentication with
+// an IAM trusted profile and the compute resource token in crTokenFilename.
+func newTrustedProfileDriverConfig(iamEndpoint, riaasEndpoint, resourceGroupID, profileID, crTokenFilename string) *DriverConfig {
+	return &DriverConfig{
+		VPC: VPCProviderConfig{
+			G2TokenExchangeEndpointURL: iamEndpoint,
+			G2RIAASEndpointURL:         riaasEndpoint,
+			G2ResourceGroupID:          resourceGroupID,
+			IAMProfileID:               profileID,
+			CRTokenFilename:            crTokenFilename,
+			ProviderType:               providerTypeG2,
+		},
+	}
+}
+
+// Marshal encodes the driver configuration as TOML. All values are quoted and escaped
+// by the encoder, so no value can break out of its string.
+func (c *DriverConfig) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", StorageSecretStoreKey, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseDriverConfig decodes slclient.toml. Keys unknown to DriverConfig are rejected.
+func ParseDriverConfig(data []byte) (*DriverConfig, error) {
+	cfg := &DriverConfig{}
+	metadata, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", StorageSecretStoreKey, err)
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("failed to decode %s: unknown keys %v", StorageSecretStoreKey, undecoded)
+	}
+	return cfg, nil
+}
+
+// Changes describes the fields of slclient.toml that differ from old, as "key: old -> new".
+// Credentials are redacted. old is nil when there was no previous configuration.
+func (c *DriverConfig) Changes(old *DriverConfig) []string {
+	if old == nil {
+		old = &DriverConfig{}
+	}
+	fields := []struct {
+		key       string
+		old, new  string
+		sensitive bool
+	}{
+		{"iam_client_id", old.VPC.IAMClientID, c.VPC.IAMClientID, false},
+		{"iam_client_secret", old.VPC.IAMClientSecret, c.VPC.IAMClientSecret, true},
+		{"g2_token_exchange_endpoint_url", old.VPC.G2TokenExchangeEndpointURL, c.VPC.G2TokenExchangeEndpointURL, false},
+		{"g2_riaas_endpoint_url", old.VPC.G2RIAASEndpointURL, c.VPC.G2RIAASEndpointURL, false},
+		{"g2_resource_group_id", old.VPC.G2ResourceGroupID, c.VPC.G2ResourceGroupID, false},
+		{"g2_api_key", old.VPC.G2APIKey, c.VPC.G2APIKey, true},
+		{"iam_profile_id", old.VPC.IAMProfileID, c.VPC.IAMProfileID, false},
+		{"cr_token_filename", old.VPC.CRTokenFilename, c.VPC.CRTokenFilename, false},
+		{"provider_type", old.VPC.ProviderType, c.VPC.ProviderType, false},
+	}
+	var changes []string
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", f.key, displayValue(f.old, f.sensitive), displayValue(f.new, f.sensitive)))
+	}
+	return changes
+}
+
+// displayValue returns value as shown in the summary of changes.
+func displayValue(value string, sensitive bool) string {
+	switch {
+	case value == "":
+		return "<unset>"
+	case sensitive:
+		return redactedValue
+	default:
+		return fmt.Sprintf("%q", value)
+	}
+}
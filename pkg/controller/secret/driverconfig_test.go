@@ -0,0 +1,190 @@
+package secret
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+func TestDriverConfigFormat(t *testing.T) {
+	expected := `[vpc]
+iam_client_id = "bx"
+iam_client_secret = "bx"
+g2_token_exchange_endpoint_url = "https://iam.cloud.ibm.com"
+g2_riaas_endpoint_url = "https://us-south.iaas.cloud.ibm.com"
+g2_resource_group_id = "fakeid"
+g2_api_key = "testapikey"
+provider_type = "g2"
+`
+	data, err := newAPIKeyDriverConfig("https://iam.cloud.ibm.com", "https://us-south.iaas.cloud.ibm.com", "fakeid", "testapikey").Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if string(data) != expected {
+		t.Errorf("Marshal() got:\n%s\nexpected:\n%s", data, expected)
+	}
+}
+
+func TestTranslateSecretRoundTrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		apiKey        string
+		resourceGroup string
+		iamEndpoint   string
+		g2Endpoint    string
+	}{
+		{
+			name:          "plain values",
+			apiKey:        "testapikey",
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+			iamEndpoint:   "https://private.iam.cloud.ibm.com",
+			g2Endpoint:    "https://us-south.private.iaas.cloud.ibm.com",
+		},
+		{
+			name:          "quote in API key",
+			apiKey:        `test"apikey`,
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "backslash in API key",
+			apiKey:        `test\apikey\`,
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "injected key in API key",
+			apiKey:        "testapikey\"\ng2_riaas_endpoint_url = \"https://attacker.example.com",
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "injected table in API key",
+			apiKey:        "x\"\n[vpc]\nprovider_type = \"g1",
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "control and unicode characters",
+			apiKey:        "key\twith\ttabs\r\né☃",
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "special characters in endpoints",
+			apiKey:        "testapikey",
+			resourceGroup: "0123456789abcdef0123456789abcdef",
+			iamEndpoint:   `https://iam.example.com/a"b\c`,
+			g2Endpoint:    "https://vpc.example.com/?q=\"x\"#\n[vpc]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudConf := newCloudConf("[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupID = " + tt.resourceGroup + "\n")
+			cloudSecret := newCloudSecret(tt.apiKey)
+			infra := ibmCloudInfrastructure("us-south", "")
+			expectedIAM, expectedRIAAS := defaultTokenExchangeURL, "https://us-south.iaas.cloud.ibm.com"
+			if tt.iamEndpoint != "" {
+				infra.Status.PlatformStatus.IBMCloud.ServiceEndpoints = append(infra.Status.PlatformStatus.IBMCloud.ServiceEndpoints,
+					configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceIAM, URL: tt.iamEndpoint},
+					configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceVPC, URL: tt.g2Endpoint})
+				expectedIAM, expectedRIAAS = tt.iamEndpoint, tt.g2Endpoint
+			}
+
+			c := newTestSecretSyncController(fakeGetResourceID, infra)
+			secret, err := c.translateSecret(context.TODO(), cloudSecret, cloudConf)
+			if err != nil {
+				t.Fatalf("translateSecret() error: %v", err)
+			}
+			actual, err := ParseDriverConfig(secret.Data[StorageSecretStoreKey])
+			if err != nil {
+				t.Fatalf("ParseDriverConfig() error: %v\n%s", err, secret.Data[StorageSecretStoreKey])
+			}
+			expected := &DriverConfig{
+				VPC: VPCProviderConfig{
+					IAMClientID:                "bx",
+					IAMClientSecret:            "bx",
+					G2TokenExchangeEndpointURL: expectedIAM,
+					G2RIAASEndpointURL:         expectedRIAAS,
+					G2ResourceGroupID:          tt.resourceGroup,
+					G2APIKey:                   tt.apiKey,
+					ProviderType:               "g2",
+				},
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("round trip got %+v, expected %+v", actual.VPC, expected.VPC)
+			}
+		})
+	}
+}
+
+func TestParseDriverConfigUnknownKeys(t *testing.T) {
+	_, err := ParseDriverConfig([]byte("[vpc]\ng2_api_key = \"key\"\nunexpected = \"value\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown keys") {
+		t.Errorf("ParseDriverConfig() expected unknown keys error, got %v", err)
+	}
+}
+
+func TestDriverConfigChanges(t *testing.T) {
+	apiKeyConfig := newAPIKeyDriverConfig("https://iam.cloud.ibm.com", "https://us-south.iaas.cloud.ibm.com", "fakeid", "oldapikey")
+	tests := []struct {
+		name     string
+		old      *DriverConfig
+		new      *DriverConfig
+		expected []string
+	}{
+		{
+			name:     "no changes",
+			old:      apiKeyConfig,
+			new:      newAPIKeyDriverConfig("https://iam.cloud.ibm.com", "https://us-south.iaas.cloud.ibm.com", "fakeid", "oldapikey"),
+			expected: nil,
+		},
+		{
+			name: "endpoint and API key changed",
+			old:  apiKeyConfig,
+			new:  newAPIKeyDriverConfig("https://private.iam.cloud.ibm.com", "https://us-south.iaas.cloud.ibm.com", "fakeid", "newapikey"),
+			expected: []string{
+				`g2_token_exchange_endpoint_url: "https://iam.cloud.ibm.com" -> "https://private.iam.cloud.ibm.com"`,
+				"g2_api_key: <redacted> -> <redacted>",
+			},
+		},
+		{
+			name: "no previous configuration",
+			old:  nil,
+			new:  apiKeyConfig,
+			expected: []string{
+				`iam_client_id: <unset> -> "bx"`,
+				"iam_client_secret: <unset> -> <redacted>",
+				`g2_token_exchange_endpoint_url: <unset> -> "https://iam.cloud.ibm.com"`,
+				`g2_riaas_endpoint_url: <unset> -> "https://us-south.iaas.cloud.ibm.com"`,
+				`g2_resource_group_id: <unset> -> "fakeid"`,
+				"g2_api_key: <unset> -> <redacted>",
+				`provider_type: <unset> -> "g2"`,
+			},
+		},
+		{
+			name: "switch to trusted profile",
+			old:  apiKeyConfig,
+			new:  newTrustedProfileDriverConfig("https://iam.cloud.ibm.com", "https://us-south.iaas.cloud.ibm.com", "fakeid", "Profile-1234", "/var/run/secrets/tokens/vault-token"),
+			expected: []string{
+				`iam_client_id: "bx" -> <unset>`,
+				"iam_client_secret: <redacted> -> <unset>",
+				"g2_api_key: <redacted> -> <unset>",
+				`iam_profile_id: <unset> -> "Profile-1234"`,
+				`cr_token_filename: <unset> -> "/var/run/secrets/tokens/vault-token"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.new.Changes(tt.old)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("Changes() got %q, expected %q", actual, tt.expected)
+			}
+			for _, change := range actual {
+				if strings.Contains(change, "apikey") {
+					t.Errorf("Changes() leaked the API key: %q", change)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// encryptionKeyCRNKeyPrefix prefixes the keys of the operator config ConfigMap with the
+	// root key of a StorageClass of the operator, e.g. encryptionKeyCRN.ibmc-vpc-block-5iops-tier.
+	// An empty value opts the StorageClass out of the EncryptionKeyCRN of the ClusterCSIDriver.
+	encryptionKeyCRNKeyPrefix = "encryptionKeyCRN."
+)
+
+// StorageClassEncryptionKeyCRN returns the CRN of the root key of StorageClass className
+// and where it is configured. The key of className in the operator config ConfigMap takes
+// precedence over the EncryptionKeyCRN of the ClusterCSIDriver. The CRN is empty when the
+// StorageClass has no root key. Both operatorConfig and ccd may be nil.
+func StorageClassEncryptionKeyCRN(operatorConfig *v1.ConfigMap, ccd *operatorv1.ClusterCSIDriver, className string) (crn, source string) {
+	if operatorConfig != nil {
+		key := encryptionKeyCRNKeyPrefix + className
+		if value, ok := operatorConfig.Data[key]; ok {
+			return strings.TrimSpace(value), fmt.Sprintf("configmap %s key %s", util.OperatorConfigMapName, key)
+		}
+	}
+	return clusterCSIDriverEncryptionKeyCRN(ccd), "EncryptionKeyCRN of ClusterCSIDriver"
+}
+
+// encryptionKeyCRNs returns the distinct root key CRNs of the ClusterCSIDriver and the
+// operator config ConfigMap, sorted. Both operatorConfig and ccd may be nil.
+func encryptionKeyCRNs(operatorConfig *v1.ConfigMap, ccd *operatorv1.ClusterCSIDriver) []string {
+	crns := map[string]bool{}
+	if crn := clusterCSIDriverEncryptionKeyCRN(ccd); crn != "" {
+		crns[crn] = true
+	}
+	if operatorConfig != nil {
+		for key, value := range operatorConfig.Data {
+			if crn := strings.TrimSpace(value); strings.HasPrefix(key, encryptionKeyCRNKeyPrefix) && crn != "" {
+				crns[crn] = true
+			}
+		}
+	}
+	var sorted []string
+	for crn := range crns {
+		sorted = append(sorted, crn)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// clusterCSIDriverEncryptionKeyCRN returns the EncryptionKeyCRN of ccd, or an empty string.
+func clusterCSIDriverEncryptionKeyCRN(ccd *operatorv1.ClusterCSIDriver) string {
+	if ccd == nil {
+		return ""
+	}
+	driverConfig := ccd.Spec.DriverConfig
+	if driverConfig.DriverType != operatorv1.IBMCloudDriverType || driverConfig.IBMCloud == nil {
+		return ""
+	}
+	return strings.TrimSpace(driverConfig.IBMCloud.EncryptionKeyCRN)
+}
@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"reflect"
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStorageClassEncryptionKeyCRN(t *testing.T) {
+	ccd := &operatorv1.ClusterCSIDriver{
+		Spec: operatorv1.ClusterCSIDriverSpec{
+			DriverConfig: operatorv1.CSIDriverConfigSpec{
+				DriverType: operatorv1.IBMCloudDriverType,
+				IBMCloud:   &operatorv1.IBMCloudCSIDriverConfigSpec{EncryptionKeyCRN: "crn-default"},
+			},
+		},
+	}
+	operatorConfig := &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: util.OperatorConfigMapName, Namespace: util.OperatorNamespace},
+		Data: map[string]string{
+			encryptionKeyCRNKeyPrefix + "gold":   " crn-gold ",
+			encryptionKeyCRNKeyPrefix + "silver": "crn-default",
+			encryptionKeyCRNKeyPrefix + "plain":  "",
+			"defaultStorageClass":                "gold",
+		},
+	}
+	tests := []struct {
+		name           string
+		operatorConfig *k8v1.ConfigMap
+		ccd            *operatorv1.ClusterCSIDriver
+		className      string
+		expected       string
+	}{
+		{name: "StorageClass key", operatorConfig: operatorConfig, ccd: ccd, className: "gold", expected: "crn-gold"},
+		{name: "ClusterCSIDriver fallback", operatorConfig: operatorConfig, ccd: ccd, className: "bronze", expected: "crn-default"},
+		{name: "empty StorageClass key", operatorConfig: operatorConfig, ccd: ccd, className: "plain", expected: ""},
+		{name: "no ConfigMap", ccd: ccd, className: "gold", expected: "crn-default"},
+		{name: "no ClusterCSIDriver", operatorConfig: operatorConfig, className: "bronze", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if crn, _ := StorageClassEncryptionKeyCRN(tt.operatorConfig, tt.ccd, tt.className); crn != tt.expected {
+				t.Errorf("StorageClassEncryptionKeyCRN() = %q, expected %q", crn, tt.expected)
+			}
+		})
+	}
+
+	if crns := encryptionKeyCRNs(operatorConfig, ccd); !reflect.DeepEqual(crns, []string{"crn-default", "crn-gold"}) {
+		t.Errorf("encryptionKeyCRNs() = %v", crns)
+	}
+}
@@ -0,0 +1,76 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// missingInputsGracePeriod is how long the cloud-credential-operator secret and cloud-conf
+	// may be missing before the operator reports Degraded. Both are created during the
+	// installation and usually appear within a few minutes.
+	missingInputsGracePeriod = 10 * time.Minute
+	// cloudInputsProgressingConditionType is True while the controller waits for the
+	// cloud-credential-operator secret or cloud-conf within missingInputsGracePeriod.
+	cloudInputsProgressingConditionType = "CloudInputsProgressing"
+	// cloudInputsDegradedConditionType is True when they are still missing after missingInputsGracePeriod.
+	cloudInputsDegradedConditionType = "CloudInputsDegraded"
+)
+
+// updateMissingInputsConditions sets the CloudInputs conditions from the objects that the
+// controller needs and cannot find, described as "kind namespace/name". Missing objects
+// are reported as progressing first and as degraded once the grace period expires.
+// manualCredentials reports the missing credentials secret as degraded right away,
+// with the keys the admin must provide.
+func (c *SecretSyncController) updateMissingInputsConditions(ctx context.Context, syncCtx factory.SyncContext, missing []string, manualCredentials bool) error {
+	progressing := operatorv1.OperatorCondition{
+		Type:   cloudInputsProgressingConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	degraded := operatorv1.OperatorCondition{
+		Type:   cloudInputsDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+
+	if len(missing) == 0 {
+		c.missingInputsSince = time.Time{}
+	} else {
+		now := c.clock.Now()
+		if c.missingInputsSince.IsZero() {
+			c.missingInputsSince = now
+		}
+		waiting := now.Sub(c.missingInputsSince)
+		switch {
+		case manualCredentials:
+			degraded.Status = operatorv1.ConditionTrue
+			degraded.Reason = "ManualCredentialsRequired"
+			degraded.Message = fmt.Sprintf("%s not found. %s", strings.Join(missing, ", "), manualCredentialsMessage())
+			klog.V(2).Infof("%s", degraded.Message)
+		case waiting < missingInputsGracePeriod:
+			progressing.Status = operatorv1.ConditionTrue
+			progressing.Reason = "WaitingForCloudInputs"
+			progressing.Message = fmt.Sprintf("Waiting for %s", strings.Join(missing, ", "))
+			// Nothing else triggers a sync when the grace period expires.
+			syncCtx.Queue().AddAfter(syncCtx.QueueKey(), missingInputsGracePeriod-waiting)
+		default:
+			degraded.Status = operatorv1.ConditionTrue
+			degraded.Reason = "CloudInputsMissing"
+			degraded.Message = fmt.Sprintf("%s not found after %s, the driver cannot start without them", strings.Join(missing, ", "), missingInputsGracePeriod)
+			klog.V(2).Infof("%s", degraded.Message)
+		}
+	}
+
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(progressing),
+		v1helpers.UpdateConditionFn(degraded))
+	return err
+}
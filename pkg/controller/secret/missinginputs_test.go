@@ -0,0 +1,175 @@
+package secret
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSyncMissingInputs(t *testing.T) {
+	cloudSecret := newCloudSecret("testapikey")
+	cloudConf := newCloudConf(testCloudConf)
+
+	tests := []struct {
+		name            string
+		objects         []runtime.Object
+		expectedMissing []string
+		unexpected      string
+	}{
+		{
+			name:            "missing secret",
+			objects:         []runtime.Object{cloudConf},
+			expectedMissing: []string{"secret " + util.OperatorNamespace + "/" + util.CloudCredentialSecretName},
+			unexpected:      util.ConfigMapName,
+		},
+		{
+			name:            "missing cloud-conf",
+			objects:         []runtime.Object{cloudSecret},
+			expectedMissing: []string{"configmap " + util.ConfigMapNamespace + "/" + util.ConfigMapName},
+			unexpected:      util.CloudCredentialSecretName,
+		},
+		{
+			name: "missing both",
+			expectedMissing: []string{
+				"secret " + util.OperatorNamespace + "/" + util.CloudCredentialSecretName,
+				"configmap " + util.ConfigMapNamespace + "/" + util.ConfigMapName,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestSecretSyncController(fakeGetResourceID, tt.objects...)
+			fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+			c.clock = fakeClock
+			syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+
+			// Within the grace period the operator is progressing, not degraded.
+			if err := c.sync(context.TODO(), syncCtx); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			progressing, degraded := missingInputsConditions(t, c)
+			if progressing.Status != operatorv1.ConditionTrue || degraded.Status != operatorv1.ConditionFalse {
+				t.Fatalf("sync() expected %s=True and %s=False, got %+v and %+v", cloudInputsProgressingConditionType, cloudInputsDegradedConditionType, progressing, degraded)
+			}
+			for _, expected := range tt.expectedMissing {
+				if !strings.Contains(progressing.Message, expected) {
+					t.Errorf("condition message %q does not contain %q", progressing.Message, expected)
+				}
+			}
+			if tt.unexpected != "" && strings.Contains(progressing.Message, tt.unexpected) {
+				t.Errorf("condition message %q reports %s as missing", progressing.Message, tt.unexpected)
+			}
+
+			fakeClock.SetTime(fakeClock.Now().Add(missingInputsGracePeriod - time.Second))
+			if err := c.sync(context.TODO(), syncCtx); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			if _, degraded := missingInputsConditions(t, c); degraded.Status != operatorv1.ConditionFalse {
+				t.Fatalf("sync() expected %s=False before the grace period expires, got %+v", cloudInputsDegradedConditionType, degraded)
+			}
+
+			// After the grace period the missing objects degrade the operator.
+			fakeClock.SetTime(fakeClock.Now().Add(time.Second))
+			if err := c.sync(context.TODO(), syncCtx); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			progressing, degraded = missingInputsConditions(t, c)
+			if progressing.Status != operatorv1.ConditionFalse || degraded.Status != operatorv1.ConditionTrue {
+				t.Fatalf("sync() expected %s=False and %s=True, got %+v and %+v", cloudInputsProgressingConditionType, cloudInputsDegradedConditionType, progressing, degraded)
+			}
+			for _, expected := range tt.expectedMissing {
+				if !strings.Contains(degraded.Message, expected) {
+					t.Errorf("condition message %q does not contain %q", degraded.Message, expected)
+				}
+			}
+
+			// Once both objects exist, the conditions are cleared and the grace period restarts.
+			ready := newTestSecretSyncController(fakeGetResourceID, cloudSecret, cloudConf)
+			c.secretLister, c.configMapLister, c.operatorConfigMapLister = ready.secretLister, ready.configMapLister, ready.operatorConfigMapLister
+			if err := c.sync(context.TODO(), syncCtx); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			progressing, degraded = missingInputsConditions(t, c)
+			if progressing.Status != operatorv1.ConditionFalse || degraded.Status != operatorv1.ConditionFalse {
+				t.Fatalf("sync() expected both conditions False, got %+v and %+v", progressing, degraded)
+			}
+			if !c.missingInputsSince.IsZero() {
+				t.Errorf("sync() did not reset the grace period")
+			}
+		})
+	}
+}
+
+func missingInputsConditions(t *testing.T, c *SecretSyncController) (*operatorv1.OperatorCondition, *operatorv1.OperatorCondition) {
+	t.Helper()
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("failed to get operator state: %v", err)
+	}
+	progressing := v1helpers.FindOperatorCondition(status.Conditions, cloudInputsProgressingConditionType)
+	degraded := v1helpers.FindOperatorCondition(status.Conditions, cloudInputsDegradedConditionType)
+	if progressing == nil || degraded == nil {
+		t.Fatalf("expected %s and %s conditions, got %+v", cloudInputsProgressingConditionType, cloudInputsDegradedConditionType, status.Conditions)
+	}
+	return progressing, degraded
+}
+
+func TestSyncManualCredentialsMode(t *testing.T) {
+	cloudConf := newCloudConf(testCloudConf)
+	tests := []struct {
+		name             string
+		mode             operatorv1.CloudCredentialsMode
+		expectedDegraded bool
+	}{
+		{
+			name: "default mode waits for the secret",
+			mode: operatorv1.CloudCredentialsModeDefault,
+		},
+		{
+			name: "mint mode waits for the secret",
+			mode: operatorv1.CloudCredentialsModeMint,
+		},
+		{
+			name:             "manual mode degrades right away",
+			mode:             operatorv1.CloudCredentialsModeManual,
+			expectedDegraded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudCredential := &operatorv1.CloudCredential{
+				ObjectMeta: metav1.ObjectMeta{Name: cloudCredentialConfigName},
+				Spec:       operatorv1.CloudCredentialSpec{CredentialsMode: tt.mode},
+			}
+			c := newTestSecretSyncController(fakeGetResourceID, cloudConf, cloudCredential)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			progressing, degraded := missingInputsConditions(t, c)
+			if !tt.expectedDegraded {
+				if progressing.Status != operatorv1.ConditionTrue || degraded.Status != operatorv1.ConditionFalse {
+					t.Errorf("sync() expected %s=True and %s=False, got %+v and %+v", cloudInputsProgressingConditionType, cloudInputsDegradedConditionType, progressing, degraded)
+				}
+				return
+			}
+			if degraded.Status != operatorv1.ConditionTrue || degraded.Reason != "ManualCredentialsRequired" {
+				t.Fatalf("sync() expected %s=True with reason ManualCredentialsRequired, got %+v", cloudInputsDegradedConditionType, degraded)
+			}
+			for _, expected := range []string{util.CloudCredentialSecretName, cloudSecretKey, trustedProfileSecretKey, util.CredentialsRequestName, util.CredentialsOverrideSecretName} {
+				if !strings.Contains(degraded.Message, expected) {
+					t.Errorf("condition message %q does not contain %q", degraded.Message, expected)
+				}
+			}
+		})
+	}
+}
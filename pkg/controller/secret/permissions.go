@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// iamPermissionsDegradedConditionType is True when the service ID of the API key misses
+	// IAM permissions the driver needs in the cluster resource group.
+	iamPermissionsDegradedConditionType = "IAMPermissionsDegraded"
+	// iamPermissionsUpgradeableConditionType is False for the same reason, as an upgrade
+	// does not fix the permissions and the driver keeps failing afterwards.
+	iamPermissionsUpgradeableConditionType = "IAMPermissionsUpgradeable"
+	// permissionsCheckInterval is how long a successful permission check is trusted for.
+	// Missing permissions are checked again on every sync, so granting them clears the
+	// condition at the next resync.
+	permissionsCheckInterval = time.Hour
+)
+
+// requiredIAMActions are the VPC Infrastructure Services actions the driver performs to
+// provision, attach, delete and snapshot volumes.
+var requiredIAMActions = []string{
+	"is.volume.volume.create",
+	"is.volume.volume.operate",
+	"is.volume.volume.delete",
+	"is.snapshot.snapshot.create",
+}
+
+// defaultMissingPermissions checks the required actions with the IAM authorization API.
+func defaultMissingPermissions(ctx context.Context, client *http.Client, apiKey, iamEndpoint, accountID, resourceGroupID string) ([]string, error) {
+	return ibmcloud.MissingPermissions(ctx, client, apiKey, iamEndpoint, accountID, resourceGroupID, requiredIAMActions)
+}
+
+// checkPermissions checks that the API key of resolved can perform the required actions in
+// the resolved resource group and sets the IAMPermissions conditions accordingly. The check
+// is advisory: when IAM cannot answer, the failure is reported in an event, the conditions
+// are left as they are and the check is retried with a backoff.
+func (c *SecretSyncController) checkPermissions(ctx context.Context, resolved *resolvedConfig) error {
+	vpc := resolved.driverConfig.VPC
+	degraded := operatorv1.OperatorCondition{
+		Type:   iamPermissionsDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	upgradeable := operatorv1.OperatorCondition{
+		Type:   iamPermissionsUpgradeableConditionType,
+		Status: operatorv1.ConditionTrue,
+		Reason: "AsExpected",
+	}
+
+	// A trusted profile is not owned by a service ID the operator can check.
+	if vpc.IAMProfileID != "" {
+		c.permissionsCheckedHash = ""
+		c.permissionsBackoff.reset()
+		degraded.Reason = "TrustedProfile"
+		degraded.Message = "IAM permissions are not checked for trusted profiles"
+		return c.updatePermissionsConditions(ctx, degraded, upgradeable)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join([]string{vpc.G2TokenExchangeEndpointURL, resolved.accountID, vpc.G2ResourceGroupID, vpc.G2APIKey}, "\n")))
+	permissionsHash := hex.EncodeToString(hash[:])
+	now := c.clock.Now()
+	if permissionsHash == c.permissionsCheckedHash && now.Sub(c.permissionsCheckedAt) < permissionsCheckInterval {
+		return nil
+	}
+	if c.permissionsBackoff.waiting(permissionsHash, now) {
+		return nil
+	}
+
+	client, err := c.httpClients.Get()
+	if err != nil {
+		return err
+	}
+	missing, err := c.missingPermissions(ctx, client, vpc.G2APIKey, vpc.G2TokenExchangeEndpointURL, resolved.accountID, vpc.G2ResourceGroupID)
+	if err != nil {
+		retryAt := c.permissionsBackoff.failed(permissionsHash, now)
+		klog.V(2).ErrorS(err, "Error while checking IAM permissions", "retryAt", retryAt)
+		c.eventRecorder.Warningf("IAMPermissionsCheckFailed", "Failed to check the IAM permissions in resource group %s, retrying after %s: %v",
+			vpc.G2ResourceGroupID, retryAt.UTC().Format(time.RFC3339), err)
+		return nil
+	}
+	c.permissionsBackoff.reset()
+
+	if len(missing) > 0 {
+		c.permissionsCheckedHash = ""
+		message := fmt.Sprintf("The service ID of secret %s/%s is not allowed to perform %s in resource group %s",
+			resolved.credentialsSecret.Namespace, resolved.credentialsSecret.Name, strings.Join(missing, ", "), vpc.G2ResourceGroupID)
+		klog.V(2).Infof("%s", message)
+		degraded.Status = operatorv1.ConditionTrue
+		degraded.Reason = "MissingPermissions"
+		degraded.Message = message
+		upgradeable.Status = operatorv1.ConditionFalse
+		upgradeable.Reason = "MissingPermissions"
+		upgradeable.Message = message
+	} else {
+		klog.V(2).Infof("IAM permissions in resource group %s are sufficient", vpc.G2ResourceGroupID)
+		c.permissionsCheckedHash = permissionsHash
+		c.permissionsCheckedAt = now
+	}
+	return c.updatePermissionsConditions(ctx, degraded, upgradeable)
+}
+
+func (c *SecretSyncController) updatePermissionsConditions(ctx context.Context, degraded, upgradeable operatorv1.OperatorCondition) error {
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(degraded),
+		v1helpers.UpdateConditionFn(upgradeable),
+	)
+	return err
+}
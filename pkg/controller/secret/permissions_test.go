@@ -0,0 +1,122 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSyncIAMPermissions(t *testing.T) {
+	cloudSecret := newCloudSecret("testapikey")
+	cloudConf := newCloudConf(testCloudConf)
+
+	c := newTestSecretSyncController(fakeGetResourceID, cloudSecret, cloudConf)
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	c.clock = fakeClock
+	var missing []string
+	var checkErr error
+	calls := 0
+	c.missingPermissions = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, accountID, resourceGroupID string) ([]string, error) {
+		calls++
+		if apiKey != "testapikey" || accountID != "testaccount" || resourceGroupID != "fakeid" {
+			t.Errorf("unexpected permission check for API key %q, account %q and resource group %q", apiKey, accountID, resourceGroupID)
+		}
+		return missing, checkErr
+	}
+	syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+
+	// Missing permissions degrade the operator and block upgrades.
+	missing = []string{"is.volume.volume.operate", "is.snapshot.snapshot.create"}
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	degraded, upgradeable := permissionsConditions(t, c)
+	if degraded.Status != operatorv1.ConditionTrue || upgradeable.Status != operatorv1.ConditionFalse {
+		t.Fatalf("expected %s=True and %s=False, got %+v and %+v", iamPermissionsDegradedConditionType, iamPermissionsUpgradeableConditionType, degraded, upgradeable)
+	}
+	for _, action := range missing {
+		if !strings.Contains(degraded.Message, action) {
+			t.Errorf("condition message %q does not contain %s", degraded.Message, action)
+		}
+	}
+	if _, err := c.kubeClient.CoreV1().Secrets(util.OperatorNamespace).Get(context.TODO(), util.IBMCSIDriverSecretName, metav1.GetOptions{}); err != nil {
+		t.Errorf("%s not published with missing permissions: %v", util.IBMCSIDriverSecretName, err)
+	}
+
+	// A failed check leaves the conditions unchanged and is retried with a backoff.
+	checkErr = errors.New("IAM is unavailable")
+	calls = 0
+	for i := 0; i < 3; i++ {
+		if err := c.sync(context.TODO(), syncCtx); err != nil {
+			t.Fatalf("sync() unexpected error: %v", err)
+		}
+	}
+	if degraded, _ := permissionsConditions(t, c); degraded.Status != operatorv1.ConditionTrue {
+		t.Errorf("failed check changed the condition to %+v", degraded)
+	}
+	if calls != 1 {
+		t.Errorf("failed check retried before %s, got %d calls", checkRetryInterval, calls)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(checkRetryInterval))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("failed check not retried after %s, got %d calls", checkRetryInterval, calls)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(checkRetryInterval))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("second failed check retried before %s, got %d calls", 2*checkRetryInterval, calls)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(checkRetryInterval))
+
+	// Granted permissions clear the conditions and are not checked again for a while.
+	missing, checkErr = nil, nil
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	degraded, upgradeable = permissionsConditions(t, c)
+	if degraded.Status != operatorv1.ConditionFalse || upgradeable.Status != operatorv1.ConditionTrue {
+		t.Fatalf("expected %s=False and %s=True, got %+v and %+v", iamPermissionsDegradedConditionType, iamPermissionsUpgradeableConditionType, degraded, upgradeable)
+	}
+	calls = 0
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("permissions checked again within %s", permissionsCheckInterval)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(permissionsCheckInterval))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("permissions not checked again after %s", permissionsCheckInterval)
+	}
+}
+
+func permissionsConditions(t *testing.T, c *SecretSyncController) (*operatorv1.OperatorCondition, *operatorv1.OperatorCondition) {
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("failed to get operator state: %v", err)
+	}
+	degraded := v1helpers.FindOperatorCondition(status.Conditions, iamPermissionsDegradedConditionType)
+	upgradeable := v1helpers.FindOperatorCondition(status.Conditions, iamPermissionsUpgradeableConditionType)
+	if degraded == nil || upgradeable == nil {
+		t.Fatalf("IAM permissions conditions not set: %+v", status.Conditions)
+	}
+	return degraded, upgradeable
+}
@@ -0,0 +1,160 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// defaultLookupBackoff retries a resource group lookup failing with a transient error
+// a few times within a sync, instead of waiting for the next resync.
+var defaultLookupBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.5,
+	Steps:    4,
+	Cap:      15 * time.Second,
+}
+
+const (
+	// Name of the key in the operator config ConfigMap with a pre-resolved resource group ID.
+	resourceGroupIDConfigKey = "resourceGroupID"
+
+	// Keys of the resource group cache ConfigMap.
+	resourceGroupCacheAccountIDKey = "accountID"
+	resourceGroupCacheNameKey      = "resourceGroupName"
+	resourceGroupCacheIDKey        = "resourceGroupID"
+)
+
+// resourceGroupIDOverride returns the resource group ID set by the admin in the operator
+// config ConfigMap, or an empty string when there is none.
+func resourceGroupIDOverride(operatorConfig *v1.ConfigMap) (string, error) {
+	if operatorConfig == nil {
+		return "", nil
+	}
+	id := strings.TrimSpace(operatorConfig.Data[resourceGroupIDConfigKey])
+	if id == "" {
+		return "", nil
+	}
+	if err := validateResourceGroupID(id); err != nil {
+		return "", fmt.Errorf("configmap %s key %s %w", util.OperatorConfigMapName, resourceGroupIDConfigKey, err)
+	}
+	return id, nil
+}
+
+// ClusterResourceGroupID returns the ID of the resource group of cloudConfig, the result of
+// EffectiveCloudConfig: its G2ResourceGroupID, or else the ID cached by the SecretSync
+// controller for its account and resource group name. An empty string is returned until
+// the ID is resolved.
+func ClusterResourceGroupID(cloudConfig *CloudConfig, operatorConfigMapLister corelisters.ConfigMapLister) (string, error) {
+	if cloudConfig.Provider.G2ResourceGroupID != "" {
+		return cloudConfig.Provider.G2ResourceGroupID, nil
+	}
+	cache, err := operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.ResourceGroupCacheConfigMapName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if cache.Data[resourceGroupCacheAccountIDKey] != cloudConfig.Provider.AccountID || cache.Data[resourceGroupCacheNameKey] != cloudConfig.Provider.G2ResourceGroupName {
+		return "", nil
+	}
+	return cache.Data[resourceGroupCacheIDKey], nil
+}
+
+// resourceGroupID returns the ID of resource group resourceGroupName in account accountID.
+// The ID resolved by Resource Manager is persisted in the resource group cache ConfigMap
+// and reused for as long as the account and the resource group name stay the same, so
+// neither IAM nor Resource Manager are called on every sync. Deleting the ConfigMap
+// forces a new lookup.
+func (c *SecretSyncController) resourceGroupID(ctx context.Context, resourceGroupName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, error) {
+	cache, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.ResourceGroupCacheConfigMapName)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil && cache.Data[resourceGroupCacheAccountIDKey] == accountID && cache.Data[resourceGroupCacheNameKey] == resourceGroupName && cache.Data[resourceGroupCacheIDKey] != "" {
+		klog.V(4).Infof("Using cached ID of resource group %s from configmap %s", resourceGroupName, util.ResourceGroupCacheConfigMapName)
+		return cache.Data[resourceGroupCacheIDKey], nil
+	}
+	if apiKey == "" {
+		return "", fmt.Errorf("resource group %s cannot be looked up without an API key, set %s in configmap %s or %s in cloud.conf",
+			resourceGroupName, resourceGroupIDConfigKey, util.OperatorConfigMapName, resourceGroupIDKey)
+	}
+
+	client, err := c.httpClients.Get()
+	if err != nil {
+		return "", err
+	}
+	resourceID, transactionID, err := c.lookupResourceGroupID(ctx, client, resourceGroupName, accountID, apiKey, rmEndpoint, iamEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), c.eventRecorder, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ResourceGroupCacheConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string]string{
+			resourceGroupCacheAccountIDKey: accountID,
+			resourceGroupCacheNameKey:      resourceGroupName,
+			resourceGroupCacheIDKey:        resourceID,
+		},
+	})
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while caching the resource group ID")
+		return "", err
+	}
+	klog.V(2).Infof("Resolved resource group %s to %s", resourceGroupName, resourceID)
+	c.eventRecorder.Eventf("ResourceGroupResolved", "Resolved resource group %s to %s, transaction ID %q", resourceGroupName, resourceID, transactionID)
+	return resourceID, nil
+}
+
+// lookupResourceGroupID calls getResourceID until it succeeds or fails with a permanent
+// error, waiting lookupBackoff between attempts. The outcome is reported in an event
+// when the lookup fails.
+func (c *SecretSyncController) lookupResourceGroupID(ctx context.Context, client *http.Client, resourceGroupName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+	var resourceID, transactionID string
+	var lookupErr error
+	attempts := 0
+	err := wait.ExponentialBackoffWithContext(ctx, c.lookupBackoff, func(ctx context.Context) (bool, error) {
+		attempts++
+		resourceID, transactionID, lookupErr = c.getResourceID(ctx, client, resourceGroupName, accountID, apiKey, rmEndpoint, iamEndpoint)
+		if lookupErr == nil {
+			return true, nil
+		}
+		if !ibmcloud.IsTransient(lookupErr) {
+			return false, lookupErr
+		}
+		klog.V(2).Infof("Transient error while looking up resource group %s, attempt %d: %v", resourceGroupName, attempts, lookupErr)
+		return false, nil
+	})
+	if err == nil {
+		return resourceID, transactionID, nil
+	}
+
+	// The backoff reports an exhausted or cancelled wait with its own error, the last
+	// error of the lookup is more useful.
+	if lookupErr != nil {
+		err = lookupErr
+	}
+	if ibmcloud.IsTransient(err) {
+		c.eventRecorder.Warningf("ResourceGroupLookupFailed", "Transient error while looking up resource group %s, giving up after %d attempts: %v", resourceGroupName, attempts, err)
+	} else {
+		c.eventRecorder.Warningf("ResourceGroupLookupFailed", "Permanent error while looking up resource group %s: %v", resourceGroupName, err)
+	}
+	return "", "", err
+}
@@ -0,0 +1,360 @@
+package secret
+
+import (
+	"context"
+	"encoding/pem"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/events"
+	k8v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func resourceGroupCache(accountID, name, id string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ResourceGroupCacheConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string]string{
+			resourceGroupCacheAccountIDKey: accountID,
+			resourceGroupCacheNameKey:      name,
+			resourceGroupCacheIDKey:        id,
+		},
+	}
+}
+
+func TestResourceGroupIDCache(t *testing.T) {
+	tests := []struct {
+		name           string
+		existingCache  *k8v1.ConfigMap
+		lookupErr      error
+		expectedLookup bool
+		expectedID     string
+		expectedCache  map[string]string
+		expectError    bool
+	}{
+		{
+			name:           "no cache",
+			expectedLookup: true,
+			expectedID:     "resolved-id",
+			expectedCache:  resourceGroupCache("testaccount", "testresource", "resolved-id").Data,
+		},
+		{
+			name:           "cache hit",
+			existingCache:  resourceGroupCache("testaccount", "testresource", "cached-id"),
+			expectedLookup: false,
+			expectedID:     "cached-id",
+			expectedCache:  resourceGroupCache("testaccount", "testresource", "cached-id").Data,
+		},
+		{
+			name:           "resource group name changed",
+			existingCache:  resourceGroupCache("testaccount", "oldresource", "cached-id"),
+			expectedLookup: true,
+			expectedID:     "resolved-id",
+			expectedCache:  resourceGroupCache("testaccount", "testresource", "resolved-id").Data,
+		},
+		{
+			name:           "account changed",
+			existingCache:  resourceGroupCache("oldaccount", "testresource", "cached-id"),
+			expectedLookup: true,
+			expectedID:     "resolved-id",
+			expectedCache:  resourceGroupCache("testaccount", "testresource", "resolved-id").Data,
+		},
+		{
+			name:           "empty cached ID",
+			existingCache:  resourceGroupCache("testaccount", "testresource", ""),
+			expectedLookup: true,
+			expectedID:     "resolved-id",
+			expectedCache:  resourceGroupCache("testaccount", "testresource", "resolved-id").Data,
+		},
+		{
+			name:           "lookup error keeps stale cache",
+			existingCache:  resourceGroupCache("testaccount", "oldresource", "cached-id"),
+			lookupErr:      fmt.Errorf("resource manager unavailable"),
+			expectedLookup: true,
+			expectedCache:  resourceGroupCache("testaccount", "oldresource", "cached-id").Data,
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.existingCache != nil {
+				objects = append(objects, tt.existingCache)
+			}
+			lookedUp := false
+			c := newTestSecretSyncController(func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+				lookedUp = true
+				if tt.lookupErr != nil {
+					return "", "", tt.lookupErr
+				}
+				return "resolved-id", "", nil
+			}, objects...)
+
+			id, err := c.resourceGroupID(context.TODO(), "testresource", "testaccount", "testapikey", defaultResourceManagerEndpoint, defaultTokenExchangeURL)
+			if err != nil && !tt.expectError {
+				t.Fatalf("resourceGroupID() unexpected error: %v", err)
+			}
+			if err == nil && tt.expectError {
+				t.Fatalf("resourceGroupID() expected error, got none")
+			}
+			if id != tt.expectedID {
+				t.Errorf("resourceGroupID() got %q, expected %q", id, tt.expectedID)
+			}
+			if lookedUp != tt.expectedLookup {
+				t.Errorf("resourceGroupID() looked up resource group: %v, expected %v", lookedUp, tt.expectedLookup)
+			}
+
+			cm, err := c.kubeClient.CoreV1().ConfigMaps(util.OperatorNamespace).Get(context.TODO(), util.ResourceGroupCacheConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) && tt.expectedCache == nil {
+					return
+				}
+				t.Fatalf("failed to get resource group cache: %v", err)
+			}
+			if !reflect.DeepEqual(cm.Data, tt.expectedCache) {
+				t.Errorf("resource group cache got %v, expected %v", cm.Data, tt.expectedCache)
+			}
+		})
+	}
+}
+
+func TestLookupResourceGroupIDRetry(t *testing.T) {
+	transientErr := &ibmcloud.APIError{Operation: "list resource groups", StatusCode: http.StatusServiceUnavailable, TransactionID: "txn-503"}
+	permanentErr := &ibmcloud.APIError{Operation: "list resource groups", StatusCode: http.StatusForbidden, TransactionID: "txn-403"}
+	tests := []struct {
+		name             string
+		results          []error
+		expectedAttempts int
+		expectError      bool
+		expectedEvent    string
+		expectedMessage  string
+	}{
+		{
+			name:             "success",
+			results:          []error{nil},
+			expectedAttempts: 1,
+			expectedEvent:    "ResourceGroupResolved",
+			expectedMessage:  `transaction ID "txn-ok"`,
+		},
+		{
+			name:             "transient errors then success",
+			results:          []error{transientErr, transientErr, nil},
+			expectedAttempts: 3,
+			expectedEvent:    "ResourceGroupResolved",
+			expectedMessage:  `transaction ID "txn-ok"`,
+		},
+		{
+			name:             "permanent error is not retried",
+			results:          []error{transientErr, permanentErr},
+			expectedAttempts: 2,
+			expectError:      true,
+			expectedEvent:    "ResourceGroupLookupFailed",
+			expectedMessage:  `Permanent error while looking up resource group testresource: failed to list resource groups with status 403, transaction ID "txn-403"`,
+		},
+		{
+			name:             "transient errors until the backoff is exhausted",
+			results:          []error{transientErr, transientErr, transientErr, transientErr},
+			expectedAttempts: 4,
+			expectError:      true,
+			expectedEvent:    "ResourceGroupLookupFailed",
+			expectedMessage:  `giving up after 4 attempts: failed to list resource groups with status 503, transaction ID "txn-503"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			c := newTestSecretSyncController(func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+				err := tt.results[attempts]
+				attempts++
+				if err != nil {
+					return "", "", err
+				}
+				return "resolved-id", "txn-ok", nil
+			})
+
+			id, err := c.resourceGroupID(context.TODO(), "testresource", "testaccount", "testapikey", defaultResourceManagerEndpoint, defaultTokenExchangeURL)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("resourceGroupID() expected error, got ID %q", id)
+				}
+			} else if err != nil || id != "resolved-id" {
+				t.Fatalf("resourceGroupID() got %q, %v, expected resolved-id", id, err)
+			}
+			if attempts != tt.expectedAttempts {
+				t.Errorf("resourceGroupID() made %d attempts, expected %d", attempts, tt.expectedAttempts)
+			}
+
+			var message string
+			for _, event := range c.eventRecorder.(events.InMemoryRecorder).Events() {
+				if event.Reason == tt.expectedEvent {
+					message = event.Message
+				}
+			}
+			if !strings.Contains(message, tt.expectedMessage) {
+				t.Errorf("event %s got message %q, expected it to contain %q", tt.expectedEvent, message, tt.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestDefaultGetResourceID(t *testing.T) {
+	tests := []struct {
+		name              string
+		iamStatus         int
+		rmStatus          int
+		rmBody            string
+		expectedID        string
+		expectError       bool
+		expectedTransient bool
+		expectedStatus    int
+	}{
+		{
+			name:       "resource group found",
+			iamStatus:  http.StatusOK,
+			rmStatus:   http.StatusOK,
+			rmBody:     `{"resources":[{"id":"0123456789abcdef0123456789abcdef","name":"testresource"}]}`,
+			expectedID: "0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:        "resource group not found",
+			iamStatus:   http.StatusOK,
+			rmStatus:    http.StatusOK,
+			rmBody:      `{"resources":[]}`,
+			expectError: true,
+		},
+		{
+			name:              "Resource Manager unavailable",
+			iamStatus:         http.StatusOK,
+			rmStatus:          http.StatusServiceUnavailable,
+			rmBody:            `{"errors":[{"code":"unavailable","message":"Service unavailable"}]}`,
+			expectError:       true,
+			expectedTransient: true,
+			expectedStatus:    http.StatusServiceUnavailable,
+		},
+		{
+			name:              "Resource Manager throttling",
+			iamStatus:         http.StatusOK,
+			rmStatus:          http.StatusTooManyRequests,
+			rmBody:            `{"errors":[{"code":"too_many_requests","message":"Too many requests"}]}`,
+			expectError:       true,
+			expectedTransient: true,
+			expectedStatus:    http.StatusTooManyRequests,
+		},
+		{
+			name:           "missing permission",
+			iamStatus:      http.StatusOK,
+			rmStatus:       http.StatusForbidden,
+			rmBody:         `{"errors":[{"code":"forbidden","message":"Forbidden"}]}`,
+			expectError:    true,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "API key rejected by IAM",
+			iamStatus:      http.StatusBadRequest,
+			expectError:    true,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iam := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(ibmcloud.TransactionIDHeader, "txn-iam")
+				w.WriteHeader(tt.iamStatus)
+				if tt.iamStatus == http.StatusOK {
+					w.Write([]byte(`{"access_token":"token","refresh_token":"not_supported","token_type":"Bearer","expires_in":3600,"expiration":4000000000}`))
+				} else {
+					w.Write([]byte(`{"errorCode":"BXNIM0415E","errorMessage":"Provided API key could not be found."}`))
+				}
+			}))
+			defer iam.Close()
+			rm := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("name") != "testresource" || r.URL.Query().Get("account_id") != "testaccount" {
+					t.Errorf("unexpected query %s", r.URL.RawQuery)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(ibmcloud.TransactionIDHeader, "txn-rm")
+				w.WriteHeader(tt.rmStatus)
+				w.Write([]byte(tt.rmBody))
+			}))
+			defer rm.Close()
+			// Both stand-ins use the same test certificate.
+			client, err := ibmcloud.NewHTTPClient(nil, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rm.Certificate().Raw}))
+			if err != nil {
+				t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+			}
+
+			id, transactionID, err := defaultGetResourceID(context.TODO(), client, "testresource", "testaccount", "testapikey", rm.URL, iam.URL)
+			if !tt.expectError {
+				if err != nil {
+					t.Fatalf("defaultGetResourceID() unexpected error: %v", err)
+				}
+				if id != tt.expectedID || transactionID != "txn-rm" {
+					t.Errorf("defaultGetResourceID() got %q, %q, expected %q, %q", id, transactionID, tt.expectedID, "txn-rm")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("defaultGetResourceID() expected error, got ID %q", id)
+			}
+			if transient := ibmcloud.IsTransient(err); transient != tt.expectedTransient {
+				t.Errorf("defaultGetResourceID() error %v transient %v, expected %v", err, transient, tt.expectedTransient)
+			}
+			var apiErr *ibmcloud.APIError
+			if tt.expectedStatus == 0 {
+				if stderrors.As(err, &apiErr) {
+					t.Errorf("defaultGetResourceID() expected a plain error, got %v", err)
+				}
+				return
+			}
+			if !stderrors.As(err, &apiErr) || apiErr.StatusCode != tt.expectedStatus || apiErr.TransactionID == "" {
+				t.Errorf("defaultGetResourceID() got %v, expected an API error with status %d and a transaction ID", err, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestDefaultGetResourceIDTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"token","refresh_token":"not_supported","token_type":"Bearer","expires_in":3600,"expiration":4000000000}`))
+			return
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// The unresponsive Resource Manager must not outlive the sync context.
+	_, _, err := defaultGetResourceID(ctx, http.DefaultClient, "testresource", "testaccount", "testapikey", server.URL, server.URL)
+	if err == nil {
+		t.Fatalf("defaultGetResourceID() expected error from an unresponsive endpoint")
+	}
+	if !ibmcloud.IsTransient(err) {
+		t.Errorf("defaultGetResourceID() expected a transient error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("defaultGetResourceID() returned after %s, expected it to honor the context", elapsed)
+	}
+}
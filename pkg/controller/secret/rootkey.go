@@ -0,0 +1,173 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// rootKeyDegradedConditionType is True when a root key of the ClusterCSIDriver or of a
+	// StorageClass does not exist, is not active or cannot be used by Block Storage for VPC.
+	rootKeyDegradedConditionType = "RootKeyDegraded"
+	// rootKeyCheckInterval is how long a successful root key check is trusted for, a key
+	// that is disabled or deleted later is reported after at most this long.
+	rootKeyCheckInterval = time.Hour
+)
+
+// defaultCheckRootKey checks key with Key Protect or Hyper Protect Crypto Services at keyEndpoint and IAM.
+func defaultCheckRootKey(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *ibmcloud.CRN) error {
+	return ibmcloud.CheckRootKey(ctx, client, apiKey, iamEndpoint, keyEndpoint, key)
+}
+
+// checkRootKey checks that the root keys of the ClusterCSIDriver and of the StorageClasses in
+// the operator config ConfigMap can encrypt volumes and sets the RootKeyDegraded condition
+// accordingly. Like the permission check, a check that cannot be made is reported in an
+// event, leaves the condition as it is and is retried with a backoff. Malformed CRNs are
+// reported by the EncryptionKeyDegraded condition and are not checked.
+func (c *SecretSyncController) checkRootKey(ctx context.Context, resolved *resolvedConfig, infra *configv1.Infrastructure) error {
+	condition := operatorv1.OperatorCondition{
+		Type:   rootKeyDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	crns, err := c.encryptionKeyCRNs()
+	if err != nil {
+		return err
+	}
+	var keys []*ibmcloud.CRN
+	for _, crn := range crns {
+		if key, err := ibmcloud.ParseEncryptionKeyCRN(crn); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	vpc := resolved.driverConfig.VPC
+	switch {
+	case len(crns) == 0:
+		c.rootKeyCheckedHash = ""
+		c.rootKeyBackoff.reset()
+		condition.Reason = "NoEncryptionKey"
+		return c.updateRootKeyCondition(ctx, condition)
+	case len(keys) == 0:
+		c.rootKeyCheckedHash = ""
+		c.rootKeyBackoff.reset()
+		condition.Reason = "InvalidCRN"
+		condition.Message = "The root keys are not checked, their CRNs are invalid"
+		return c.updateRootKeyCondition(ctx, condition)
+	case vpc.IAMProfileID != "":
+		c.rootKeyCheckedHash = ""
+		c.rootKeyBackoff.reset()
+		condition.Reason = "TrustedProfile"
+		condition.Message = "The root keys are not checked for trusted profiles"
+		return c.updateRootKeyCondition(ctx, condition)
+	}
+
+	checked := []string{vpc.G2TokenExchangeEndpointURL, vpc.G2APIKey}
+	for _, key := range keys {
+		checked = append(checked, key.String(), keyManagementEndpoint(infra, key))
+	}
+	hash := sha256.Sum256([]byte(strings.Join(checked, "\n")))
+	rootKeyHash := hex.EncodeToString(hash[:])
+	now := c.clock.Now()
+	if rootKeyHash == c.rootKeyCheckedHash && now.Sub(c.rootKeyCheckedAt) < rootKeyCheckInterval {
+		return nil
+	}
+	if c.rootKeyBackoff.waiting(rootKeyHash, now) {
+		return nil
+	}
+
+	client, err := c.httpClients.Get()
+	if err != nil {
+		return err
+	}
+	var reasons, messages []string
+	for _, key := range keys {
+		err := c.checkRootKeyUsable(ctx, client, vpc.G2APIKey, vpc.G2TokenExchangeEndpointURL, keyManagementEndpoint(infra, key), key)
+		var keyErr *ibmcloud.RootKeyError
+		switch {
+		case errors.As(err, &keyErr):
+			klog.V(2).Infof("%v", keyErr)
+			reasons = append(reasons, keyErr.Reason)
+			messages = append(messages, keyErr.Error())
+		case err != nil:
+			retryAt := c.rootKeyBackoff.failed(rootKeyHash, now)
+			klog.V(2).ErrorS(err, "Error while checking the root key", "crn", key.String(), "retryAt", retryAt)
+			c.eventRecorder.Warningf("RootKeyCheckFailed", "Failed to check root key %s, retrying after %s: %v",
+				key, retryAt.UTC().Format(time.RFC3339), err)
+			return nil
+		default:
+			klog.V(2).Infof("Root key %s is usable", key)
+		}
+	}
+	c.rootKeyBackoff.reset()
+
+	if len(messages) > 0 {
+		c.rootKeyCheckedHash = ""
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = reasons[0]
+		for _, reason := range reasons {
+			if reason != reasons[0] {
+				condition.Reason = "UnusableRootKeys"
+			}
+		}
+		condition.Message = "New volumes cannot be encrypted with their root keys: " + strings.Join(messages, "; ")
+	} else {
+		c.rootKeyCheckedHash = rootKeyHash
+		c.rootKeyCheckedAt = now
+	}
+	return c.updateRootKeyCondition(ctx, condition)
+}
+
+// encryptionKeyCRNs returns the root key CRNs of the ClusterCSIDriver and of the
+// StorageClasses in the operator config ConfigMap.
+func (c *SecretSyncController) encryptionKeyCRNs() ([]string, error) {
+	ccd, err := c.clusterCSIDriverLister.Get(util.InstanceName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		ccd = nil
+	}
+	operatorConfig, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		operatorConfig = nil
+	}
+	return encryptionKeyCRNs(operatorConfig, ccd), nil
+}
+
+// keyManagementEndpoint returns the endpoint of the key management service of key, the
+// Infrastructure one when it overrides the Key Protect or Hyper Protect endpoint.
+func keyManagementEndpoint(infra *configv1.Infrastructure, key *ibmcloud.CRN) string {
+	service := configv1.IBMCloudServiceKeyProtect
+	if key.ServiceName == ibmcloud.HPCSServiceName {
+		service = configv1.IBMCloudServiceHyperProtect
+	}
+	if infra != nil && infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.IBMCloud != nil {
+		for _, endpoint := range infra.Status.PlatformStatus.IBMCloud.ServiceEndpoints {
+			if endpoint.Name == service && endpoint.URL != "" {
+				return endpoint.URL
+			}
+		}
+	}
+	return ibmcloud.KeyManagementEndpoint(key)
+}
+
+func (c *SecretSyncController) updateRootKeyCondition(ctx context.Context, condition operatorv1.OperatorCondition) error {
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(condition))
+	return err
+}
@@ -0,0 +1,223 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+const testRootKeyCRN = "crn:v1:bluemix:public:kms:us-south:a/testaccount:d5e11420-3f0f-447a-92c0-13c978cf9096:key:c4a72f5b-9412-4ce8-80a4-2acb1987f3ba"
+
+func clusterCSIDriver(encryptionKeyCRN string) *operatorv1.ClusterCSIDriver {
+	return &operatorv1.ClusterCSIDriver{
+		ObjectMeta: metav1.ObjectMeta{Name: util.InstanceName},
+		Spec: operatorv1.ClusterCSIDriverSpec{
+			DriverConfig: operatorv1.CSIDriverConfigSpec{
+				DriverType: operatorv1.IBMCloudDriverType,
+				IBMCloud:   &operatorv1.IBMCloudCSIDriverConfigSpec{EncryptionKeyCRN: encryptionKeyCRN},
+			},
+		},
+	}
+}
+
+func TestSyncRootKey(t *testing.T) {
+	cloudSecret := newCloudSecret("testapikey")
+	cloudConf := newCloudConf(testCloudConf)
+	privateKeyProtect := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infraConfigName},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.IBMCloudPlatformType,
+				IBMCloud: &configv1.IBMCloudPlatformStatus{
+					ServiceEndpoints: []configv1.IBMCloudServiceEndpoint{
+						{Name: configv1.IBMCloudServiceKeyProtect, URL: "https://private.us-south.kms.cloud.ibm.com"},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		checkErr         error
+		expectedEndpoint string
+		expectedStatus   operatorv1.ConditionStatus
+		expectedReason   string
+	}{
+		{
+			name:           "no ClusterCSIDriver",
+			expectedStatus: operatorv1.ConditionFalse,
+			expectedReason: "NoEncryptionKey",
+		},
+		{
+			name:           "invalid CRN",
+			objects:        []runtime.Object{clusterCSIDriver("my-key")},
+			expectedStatus: operatorv1.ConditionFalse,
+			expectedReason: "InvalidCRN",
+		},
+		{
+			name:             "usable root key",
+			objects:          []runtime.Object{clusterCSIDriver(testRootKeyCRN)},
+			expectedEndpoint: "https://us-south.kms.cloud.ibm.com",
+			expectedStatus:   operatorv1.ConditionFalse,
+			expectedReason:   "AsExpected",
+		},
+		{
+			name:             "Key Protect endpoint of the Infrastructure",
+			objects:          []runtime.Object{clusterCSIDriver(testRootKeyCRN), privateKeyProtect},
+			expectedEndpoint: "https://private.us-south.kms.cloud.ibm.com",
+			expectedStatus:   operatorv1.ConditionFalse,
+			expectedReason:   "AsExpected",
+		},
+		{
+			name: "root key of a StorageClass",
+			objects: []runtime.Object{&k8v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: util.OperatorConfigMapName, Namespace: util.OperatorNamespace},
+				Data:       map[string]string{encryptionKeyCRNKeyPrefix + "ibmc-vpc-block-5iops-tier": testRootKeyCRN},
+			}},
+			expectedEndpoint: "https://us-south.kms.cloud.ibm.com",
+			expectedStatus:   operatorv1.ConditionFalse,
+			expectedReason:   "AsExpected",
+		},
+		{
+			name:             "deleted root key",
+			objects:          []runtime.Object{clusterCSIDriver(testRootKeyCRN)},
+			checkErr:         &ibmcloud.RootKeyError{CRN: testRootKeyCRN, Reason: ibmcloud.RootKeyNotFound, Message: "key does not exist"},
+			expectedEndpoint: "https://us-south.kms.cloud.ibm.com",
+			expectedStatus:   operatorv1.ConditionTrue,
+			expectedReason:   ibmcloud.RootKeyNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestSecretSyncController(fakeGetResourceID, append(tt.objects, cloudSecret, cloudConf)...)
+			calls := 0
+			c.checkRootKeyUsable = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *ibmcloud.CRN) error {
+				calls++
+				if apiKey != "testapikey" || keyEndpoint != tt.expectedEndpoint || key.String() != testRootKeyCRN {
+					t.Errorf("unexpected root key check of %s at %s with API key %q", key, keyEndpoint, apiKey)
+				}
+				return tt.checkErr
+			}
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			if tt.expectedEndpoint == "" && calls != 0 {
+				t.Errorf("root key checked %d times, expected none", calls)
+			}
+			condition := rootKeyCondition(t, c)
+			if condition.Status != tt.expectedStatus || condition.Reason != tt.expectedReason {
+				t.Errorf("expected %s=%s with reason %s, got %+v", rootKeyDegradedConditionType, tt.expectedStatus, tt.expectedReason, condition)
+			}
+		})
+	}
+}
+
+func TestSyncRootKeyRecheck(t *testing.T) {
+	cloudSecret := newCloudSecret("testapikey")
+	cloudConf := newCloudConf(testCloudConf)
+	c := newTestSecretSyncController(fakeGetResourceID, cloudSecret, cloudConf, clusterCSIDriver(testRootKeyCRN))
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	c.clock = fakeClock
+	var checkErr error
+	calls := 0
+	c.checkRootKeyUsable = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *ibmcloud.CRN) error {
+		calls++
+		return checkErr
+	}
+	syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+
+	// A disabled key degrades the operator and is checked again on every sync.
+	checkErr = &ibmcloud.RootKeyError{CRN: testRootKeyCRN, Reason: ibmcloud.RootKeyNotActive, Message: "key is in state Suspended"}
+	for i := 0; i < 2; i++ {
+		if err := c.sync(context.TODO(), syncCtx); err != nil {
+			t.Fatalf("sync() unexpected error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("unusable root key checked %d times in 2 syncs", calls)
+	}
+	if condition := rootKeyCondition(t, c); condition.Status != operatorv1.ConditionTrue {
+		t.Fatalf("expected %s=True, got %+v", rootKeyDegradedConditionType, condition)
+	}
+
+	// A failed check leaves the condition unchanged and is retried with a backoff.
+	checkErr = errors.New("Key Protect is unavailable")
+	calls = 0
+	for i := 0; i < 2; i++ {
+		if err := c.sync(context.TODO(), syncCtx); err != nil {
+			t.Fatalf("sync() unexpected error: %v", err)
+		}
+	}
+	if condition := rootKeyCondition(t, c); condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("failed check changed the condition to %+v", condition)
+	}
+	if calls != 1 {
+		t.Errorf("failed check retried before %s, got %d calls", checkRetryInterval, calls)
+	}
+	if events := countEvents(c, "RootKeyCheckFailed"); events != 1 {
+		t.Errorf("expected 1 RootKeyCheckFailed event, got %d", events)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(checkRetryInterval))
+
+	// An enabled key clears the condition and is not checked again for a while.
+	checkErr = nil
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if condition := rootKeyCondition(t, c); condition.Status != operatorv1.ConditionFalse {
+		t.Fatalf("expected %s=False, got %+v", rootKeyDegradedConditionType, condition)
+	}
+	calls = 0
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("root key checked again within %s", rootKeyCheckInterval)
+	}
+	fakeClock.SetTime(fakeClock.Now().Add(rootKeyCheckInterval))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("root key not checked again after %s", rootKeyCheckInterval)
+	}
+}
+
+// countEvents returns the number of events with reason recorded by c.
+func countEvents(c *SecretSyncController, reason string) int {
+	count := 0
+	for _, event := range c.eventRecorder.(events.InMemoryRecorder).Events() {
+		if event.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
+func rootKeyCondition(t *testing.T, c *SecretSyncController) *operatorv1.OperatorCondition {
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("failed to get operator state: %v", err)
+	}
+	condition := v1helpers.FindOperatorCondition(status.Conditions, rootKeyDegradedConditionType)
+	if condition == nil {
+		t.Fatalf("%s condition not set: %+v", rootKeyDegradedConditionType, status.Conditions)
+	}
+	return condition
+}
@@ -3,12 +3,19 @@ package secret
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/IBM/go-sdk-core/v5/core"
 	"github.com/IBM/platform-services-go-sdk/resourcemanagerv2"
+	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/openshift/api/operator/v1"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	operatorinformers "github.com/openshift/client-go/operator/informers/externalversions"
+	operatorlisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
 	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -16,10 +23,11 @@ import (
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 )
 
 // This SecretSyncController translates Secret provided by cloud-credential-operator into
@@ -29,11 +37,42 @@ type SecretSyncController struct {
 	kubeClient      kubernetes.Interface
 	secretLister    corelisters.SecretLister
 	configMapLister corelisters.ConfigMapLister
-	eventRecorder   events.Recorder
-	getResourceID   func(resourceName, accountID, apiKey, resourceManagerEndpoint, iamEndpoint string) (string, error)
+	// operatorConfigMapLister lists ConfigMaps in the operator namespace.
+	operatorConfigMapLister corelisters.ConfigMapLister
+	infraLister             configlisters.InfrastructureLister
+	cloudCredentialLister   operatorlisters.CloudCredentialLister
+	clusterCSIDriverLister  operatorlisters.ClusterCSIDriverLister
+	eventRecorder           events.Recorder
+	// httpClients is the client of the calls to the IBM Cloud APIs.
+	httpClients        *ibmcloud.HTTPClientCache
+	getResourceID      func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, resourceManagerEndpoint, iamEndpoint string) (resourceID, transactionID string, err error)
+	validateAPIKey     func(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error
+	missingPermissions func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, accountID, resourceGroupID string) ([]string, error)
+	checkRootKeyUsable func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *ibmcloud.CRN) error
+	// lookupBackoff is the backoff between attempts of a resource group lookup failing with a transient error.
+	lookupBackoff wait.Backoff
+	// validatedCredentialsHash is the hash of the last API key and IAM endpoint accepted by IAM.
+	validatedCredentialsHash string
+	// permissionsCheckedHash is the hash of the last API key, account and resource group
+	// with all required IAM permissions, checked at permissionsCheckedAt.
+	permissionsCheckedHash string
+	permissionsCheckedAt   time.Time
+	// permissionsBackoff delays the permission checks that could not be made.
+	permissionsBackoff checkBackoff
+	// rootKeyCheckedHash is the hash of the last usable root key with the API key and the
+	// endpoints it was checked with at rootKeyCheckedAt.
+	rootKeyCheckedHash string
+	rootKeyCheckedAt   time.Time
+	// rootKeyBackoff delays the root key checks that could not be made.
+	rootKeyBackoff checkBackoff
+	clock          clock.PassiveClock
+	// missingInputsSince is when the controller started waiting for a missing secret or cloud-conf.
+	missingInputsSince time.Time
 }
 
 const (
+	// Name of the cluster-scoped Infrastructure object.
+	infraConfigName = "cluster"
 	// Name of key with ibmcloud_api_key in Secret provided by cloud-credentials-operator.
 	cloudSecretKey = "ibmcloud_api_key"
 	// Name of key with cloud.conf in ConfigMap provided by cloud-credentials-operator.
@@ -52,22 +91,14 @@ const (
 	g2EndpointOverride = "g2EndpointOverride"
 	// rmEndpointOverride is the key expected in cloud conf config map for resource manager endpoint.
 	rmEndpointOverride = "rmEndpointOverride"
-	// storage-secret-store data format
-	StorageSecretTomlTemplate = `[vpc]
-iam_client_id = "bx"
-iam_client_secret = "bx"
-g2_token_exchange_endpoint_url = "%s"
-g2_riaas_endpoint_url = "%s"
-g2_resource_group_id = "%s"
-g2_api_key = "%s"
-provider_type = "g2"
-`
 )
 
 func NewSecretSyncController(
 	operatorClient v1helpers.OperatorClient,
 	kubeClient kubernetes.Interface,
 	informers v1helpers.KubeInformersForNamespaces,
+	configInformers configinformers.SharedInformerFactory,
+	operatorInformers operatorinformers.SharedInformerFactory,
 	resync time.Duration,
 	eventRecorder events.Recorder) factory.Controller {
 
@@ -75,17 +106,42 @@ func NewSecretSyncController(
 	secretInformer := informers.InformersFor(util.OperatorNamespace)
 	configMapInformer := informers.InformersFor(util.ConfigMapNamespace)
 	c := &SecretSyncController{
-		operatorClient:  operatorClient,
-		kubeClient:      kubeClient,
-		secretLister:    secretInformer.Core().V1().Secrets().Lister(),
-		configMapLister: configMapInformer.Core().V1().ConfigMaps().Lister(),
-		eventRecorder:   eventRecorder.WithComponentSuffix("SecretSync"),
-		getResourceID:   defaultGetResourceID,
+		operatorClient:          operatorClient,
+		kubeClient:              kubeClient,
+		secretLister:            secretInformer.Core().V1().Secrets().Lister(),
+		configMapLister:         configMapInformer.Core().V1().ConfigMaps().Lister(),
+		operatorConfigMapLister: secretInformer.Core().V1().ConfigMaps().Lister(),
+		infraLister:             configInformers.Config().V1().Infrastructures().Lister(),
+		cloudCredentialLister:   operatorInformers.Operator().V1().CloudCredentials().Lister(),
+		clusterCSIDriverLister:  operatorInformers.Operator().V1().ClusterCSIDrivers().Lister(),
+		eventRecorder:           eventRecorder.WithComponentSuffix("SecretSync"),
+		httpClients: ibmcloud.NewHTTPClientCache(
+			configInformers.Config().V1().Proxies().Lister(),
+			secretInformer.Core().V1().ConfigMaps().Lister(),
+		),
+		getResourceID:      defaultGetResourceID,
+		validateAPIKey:     defaultValidateAPIKey,
+		missingPermissions: defaultMissingPermissions,
+		checkRootKeyUsable: defaultCheckRootKey,
+		lookupBackoff:      defaultLookupBackoff,
+		clock:              clock.RealClock{},
 	}
 	return factory.New().WithSync(c.sync).ResyncEvery(resync).WithSyncDegradedOnError(operatorClient).WithInformers(
 		operatorClient.Informer(),
 		secretInformer.Core().V1().Secrets().Informer(),
-		configMapInformer.Core().V1().Secrets().Informer(),
+		configInformers.Config().V1().Infrastructures().Informer(),
+		configInformers.Config().V1().Proxies().Informer(),
+		operatorInformers.Operator().V1().CloudCredentials().Informer(),
+		operatorInformers.Operator().V1().ClusterCSIDrivers().Informer(),
+	).WithFilteredEventsInformers(
+		// The operator config, the resource group cache and the trusted CA bundle are the inputs in the
+		// operator namespace. The status ConfigMap is left out so the controller's own writes don't re-queue it.
+		factory.NamesFilter(util.OperatorConfigMapName, util.ResourceGroupCacheConfigMapName, util.TrustedCAConfigMap),
+		secretInformer.Core().V1().ConfigMaps().Informer(),
+	).WithFilteredEventsInformers(
+		// Only cloud-conf is relevant in the cloud controller manager namespace.
+		factory.NamesFilter(util.ConfigMapName),
+		configMapInformer.Core().V1().ConfigMaps().Informer(),
 	).ToController("SecretSync", eventRecorder)
 }
 
@@ -100,151 +156,272 @@ func (c *SecretSyncController) sync(ctx context.Context, syncCtx factory.SyncCon
 		return nil
 	}
 
-	cloudSecret, err := c.secretLister.Secrets(util.OperatorNamespace).Get(util.CloudCredentialSecretName)
+	// The secret and cloud-conf are created during the installation, report them as missing
+	// only when they do not show up within the grace period. An admin-supplied credentials
+	// override secret replaces the cloud-credential-operator one.
+	// In Manual mode, cloud-credential-operator never creates the secret and there is no point in waiting.
+	var missing []string
+	manualCredentials := false
+	credentialsSecret, err := GetCredentialsSecret(c.secretLister)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			// TODO: report error after some while?
-			klog.V(2).Infof("Waiting for secret %s from %s", util.CloudCredentialSecretName, util.OperatorNamespace)
-			return nil
+		if !errors.IsNotFound(err) {
+			klog.V(2).ErrorS(err, "Secret listener failed to get secret details")
+			return err
+		}
+		klog.V(2).Infof("Waiting for secret %s from %s", util.CloudCredentialSecretName, util.OperatorNamespace)
+		missing = append(missing, fmt.Sprintf("secret %s/%s", util.OperatorNamespace, util.CloudCredentialSecretName))
+		if manualCredentials, err = c.credentialsModeManual(); err != nil {
+			klog.V(2).ErrorS(err, "CloudCredential listener failed to get the credentials mode")
+			return err
 		}
-		klog.V(2).ErrorS(err, "Secret listener failed to get secret details")
-		return err
 	}
 
 	cloudConfConfigMap, err := c.configMapLister.ConfigMaps(util.ConfigMapNamespace).Get(util.ConfigMapName)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			// TODO: report error after some while?
-			klog.V(2).Infof("Waiting for configmap %s from %s", util.ConfigMapName, util.ConfigMapNamespace)
-			return nil
+		if !errors.IsNotFound(err) {
+			klog.V(2).ErrorS(err, "Configmap listener failed to get cm details")
+			return err
 		}
-		klog.V(2).ErrorS(err, "Configmap listener failed to get cm details")
+		klog.V(2).Infof("Waiting for configmap %s from %s", util.ConfigMapName, util.ConfigMapNamespace)
+		missing = append(missing, fmt.Sprintf("configmap %s/%s", util.ConfigMapNamespace, util.ConfigMapName))
+	}
+
+	if err := c.updateMissingInputsConditions(ctx, syncCtx, missing, manualCredentials); err != nil {
+		klog.V(2).ErrorS(err, "Error while updating the missing inputs conditions")
+		return err
+	}
+	if len(missing) > 0 {
+		return nil
+	}
+	if err := c.updateCredentialsSourceCondition(ctx, credentialsSecret); err != nil {
+		klog.V(2).ErrorS(err, "Error while updating the credentials source condition")
 		return err
 	}
 
-	// Get the storage-secret-store secret to be created from ibm-cloud-credential secret and clod-conf configmap
-	driverSecret, err := c.translateSecret(cloudSecret, cloudConfConfigMap)
+	infra, err := c.infrastructure()
+	if err != nil {
+		klog.V(2).ErrorS(err, "Infrastructure listener failed to get infrastructure details")
+		return err
+	}
+
+	// Get the storage-secret-store secret to be created from the credentials secret, cloud-conf configmap and infrastructure
+	// An API key rejected by IAM is not published, the driver keeps using the last valid secret.
+	resolved, err := c.resolveConfig(ctx, credentialsSecret, cloudConfConfigMap, infra)
+	if condErr := c.updateCredentialsCondition(ctx, credentialsSecret, err); condErr != nil {
+		klog.V(2).ErrorS(condErr, "Error while updating the credentials condition")
+		return condErr
+	}
 	if err != nil {
 		klog.V(2).ErrorS(err, "Error while extracting data from secret/cm")
 		return err
 	}
-	_, _, err = resourceapply.ApplySecret(ctx, c.kubeClient.CoreV1(), c.eventRecorder, driverSecret)
+	driverSecret, err := resolved.driverSecret()
+	if err != nil {
+		return err
+	}
+	changes := c.driverConfigChanges(driverSecret)
+	_, modified, err := resourceapply.ApplySecret(ctx, c.kubeClient.CoreV1(), c.eventRecorder, driverSecret)
 	if err != nil {
 		klog.V(2).ErrorS(err, "Error while creating the secret")
 		return err
 	}
+	if modified && len(changes) > 0 {
+		c.eventRecorder.Eventf("StorageSecretStoreChanged", "%s regenerated: %s", util.IBMCSIDriverSecretName, strings.Join(changes, ", "))
+	}
 	klog.V(2).Infof("%s secret created successfully", util.IBMCSIDriverSecretName)
+
+	if err := c.checkPermissions(ctx, resolved); err != nil {
+		klog.V(2).ErrorS(err, "Error while updating the IAM permissions conditions")
+		return err
+	}
+
+	if err := c.checkRootKey(ctx, resolved, infra); err != nil {
+		klog.V(2).ErrorS(err, "Error while updating the root key condition")
+		return err
+	}
+
+	if err := c.publishStatus(ctx, resolved); err != nil {
+		klog.V(2).ErrorS(err, "Error while publishing the driver configuration status")
+		return err
+	}
 	return nil
 }
 
-func (c *SecretSyncController) translateSecret(cloudSecret *v1.Secret, cloudConf *v1.ConfigMap) (*v1.Secret, error) {
-	apiKey, ok := cloudSecret.Data[cloudSecretKey]
-	if !ok {
-		return nil, fmt.Errorf("cloud-credential-operator secret %s did not contain key %s", util.CloudCredentialSecretName, cloudSecretKey)
-	}
-	conf, ok := cloudConf.Data[CloudConfigmapKey]
-	if !ok {
-		return nil, fmt.Errorf("cloud-credential-operator configmap %s did not contain key %s", util.ConfigMapName, cloudSecretKey)
+// infrastructure returns the Infrastructure of the cluster, or nil when it does not exist.
+// Infrastructure is the authoritative source of the location, resource group and endpoints,
+// cloud-conf is used for anything it does not provide.
+func (c *SecretSyncController) infrastructure() (*configv1.Infrastructure, error) {
+	infra, err := c.infraLister.Get(infraConfigName)
+	if errors.IsNotFound(err) {
+		klog.V(2).Infof("Infrastructure %s not found, using %s only", infraConfigName, util.ConfigMapName)
+		return nil, nil
 	}
+	return infra, err
+}
 
-	var re *regexp.Regexp
-	var match []string
+// translateSecret builds storage-secret-store from credentialsSecret, which is either the
+// cloud-credential-operator secret or the credentials override secret, cloud.conf and the
+// Infrastructure of the cluster.
+func (c *SecretSyncController) translateSecret(ctx context.Context, credentialsSecret *v1.Secret, cloudConf *v1.ConfigMap) (*v1.Secret, error) {
+	infra, err := c.infrastructure()
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := c.resolveConfig(ctx, credentialsSecret, cloudConf, infra)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.driverSecret()
+}
 
-	// Extracting the region from configmap
-	re = regexp.MustCompile("region = (.*?)\n")
-	match = re.FindStringSubmatch(conf)
-	if len(match) <= 0 {
-		return nil, fmt.Errorf("cloud-credential-operator configmap %s did not contain region", util.ConfigMapName)
+// resolveConfig computes the configuration of the driver from credentialsSecret, cloud.conf
+// and infra, looking the resource group up when needed.
+func (c *SecretSyncController) resolveConfig(ctx context.Context, credentialsSecret *v1.Secret, cloudConf *v1.ConfigMap, infra *configv1.Infrastructure) (*resolvedConfig, error) {
+	operatorConfig, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		operatorConfig = nil
 	}
-	region := match[1]
 
-	re = regexp.MustCompile("g2ResourceGroupName = (.*?)\n")
-	match = re.FindStringSubmatch(conf)
-	if len(match) <= 1 {
-		return nil, fmt.Errorf("cloud-credential-operator configmap %s did not contain resourcegroupname", util.ConfigMapName)
+	// With a trusted profile, the driver exchanges a projected service account token for
+	// a compute resource token and does not need any API key.
+	trustedProfileID, err := TrustedProfileID(credentialsSecret, operatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, ok := credentialsSecret.Data[cloudSecretKey]
+	if !ok && trustedProfileID == "" {
+		return nil, fmt.Errorf("secret %s did not contain key %s", credentialsSecret.Name, cloudSecretKey)
+	}
+	if trustedProfileID != "" {
+		apiKey = nil
+	}
+	conf, ok := cloudConf.Data[CloudConfigmapKey]
+	if !ok {
+		return nil, fmt.Errorf("cloud-credential-operator configmap %s did not contain key %s", util.ConfigMapName, CloudConfigmapKey)
 	}
-	resourceGroupName := match[1]
 
-	re = regexp.MustCompile("accountID = (.*?)\n")
-	match = re.FindStringSubmatch(conf)
-	if len(match) <= 1 {
-		return nil, fmt.Errorf("cloud-credential-operator configmap %s did not contain accountID", util.ConfigMapName)
+	cloudConfig, err := ParseCloudConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("cloud-credential-operator configmap %s is invalid: %w", util.ConfigMapName, err)
+	}
+	cloudConfig.ApplyInfrastructure(infra)
+	if err := cloudConfig.applyOverrides(credentialsSecret, operatorConfig); err != nil {
+		return nil, err
+	}
+	if err := cloudConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("cloud-credential-operator configmap %s is invalid: %w", util.ConfigMapName, err)
 	}
-	accountID := match[1]
+	provider := cloudConfig.Provider
+	region := provider.Region
+	resourceGroupName := provider.G2ResourceGroupName
+	accountID := provider.AccountID
 
-	iamEndpoint := fetchEndpoint(iamEndpointOverride, conf, defaultTokenExchangeURL)
+	iamEndpoint := endpointOrDefault(iamEndpointOverride, provider.IAMEndpointOverride, defaultTokenExchangeURL)
 
-	riaasEndpoint := fetchEndpoint(g2EndpointOverride, conf, fmt.Sprintf(defaultRIAASEndpointURL, region))
+	riaasEndpoint := endpointOrDefault(g2EndpointOverride, provider.G2EndpointOverride, fmt.Sprintf(defaultRIAASEndpointURL, region))
 
-	resourceManagerEndpoint := fetchEndpoint(rmEndpointOverride, conf, defaultResourceManagerEndpoint)
+	resourceManagerEndpoint := endpointOrDefault(rmEndpointOverride, provider.RMEndpointOverride, defaultResourceManagerEndpoint)
 
-	resourceId, err := c.getResourceID(resourceGroupName, accountID, string(apiKey), resourceManagerEndpoint, iamEndpoint)
-	if err != nil {
-		return nil, err
+	if trustedProfileID == "" {
+		if err := c.validateCredentials(ctx, string(apiKey), iamEndpoint); err != nil {
+			return nil, err
+		}
 	}
 
-	// Creating secret data storage-secret-store
-	tomlData := fmt.Sprintf(StorageSecretTomlTemplate, iamEndpoint, riaasEndpoint, resourceId, apiKey)
-	data := make(map[string][]byte)
-	data[StorageSecretStoreKey] = []byte(tomlData)
-	secret := v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      util.IBMCSIDriverSecretName,
-			Namespace: util.OperatorNamespace,
-		},
-		Type: v1.SecretTypeOpaque,
-		Data: data,
+	// A resource group ID provided by the admin or in cloud.conf is used as it is,
+	// without asking Resource Manager, which requires a viewer role on the account.
+	resourceId := provider.G2ResourceGroupID
+	if resourceId == "" {
+		resourceId, err = c.resourceGroupID(ctx, resourceGroupName, accountID, string(apiKey), resourceManagerEndpoint, iamEndpoint)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &secret, nil
+	driverConfig := newAPIKeyDriverConfig(iamEndpoint, riaasEndpoint, resourceId, string(apiKey))
+	if trustedProfileID != "" {
+		driverConfig = newTrustedProfileDriverConfig(iamEndpoint, riaasEndpoint, resourceId, trustedProfileID, ComputeResourceTokenDir+"/"+ComputeResourceTokenFile)
+	}
+	return &resolvedConfig{
+		credentialsSecret:       credentialsSecret,
+		region:                  region,
+		accountID:               accountID,
+		resourceGroupName:       resourceGroupName,
+		resourceManagerEndpoint: resourceManagerEndpoint,
+		driverConfig:            driverConfig,
+	}, nil
 }
 
-// fetchEndpoint fetches the url provided against endpointkey
-func fetchEndpoint(endpointkey, conf, defaultEndpointValue string) string {
-	var re *regexp.Regexp
-	var match []string
-
-	// Fetch url provided against endpointkey
-	re = regexp.MustCompile(fmt.Sprintf("%s = (.*?)\n", endpointkey))
-	match = re.FindStringSubmatch(conf)
-	// If the url doesn't exist return default value
-	if len(match) <= 1 || match[1] == "" {
-		klog.V(2).Infof("%s is not provided", endpointkey)
-		return defaultEndpointValue
+// driverConfigChanges summarizes the differences between the driver configuration in
+// driverSecret and the one currently published, with credentials redacted.
+func (c *SecretSyncController) driverConfigChanges(driverSecret *v1.Secret) []string {
+	newConfig, err := ParseDriverConfig(driverSecret.Data[StorageSecretStoreKey])
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while parsing the generated driver configuration")
+		return nil
 	}
+	var oldConfig *DriverConfig
+	existing, err := c.secretLister.Secrets(util.OperatorNamespace).Get(util.IBMCSIDriverSecretName)
+	if err == nil {
+		// A secret that cannot be parsed is reported as if all fields changed.
+		oldConfig, err = ParseDriverConfig(existing.Data[StorageSecretStoreKey])
+		if err != nil {
+			klog.V(4).Infof("Existing %s cannot be parsed: %v", util.IBMCSIDriverSecretName, err)
+			oldConfig = nil
+		}
+	} else if !errors.IsNotFound(err) {
+		klog.V(2).ErrorS(err, "Secret listener failed to get the existing driver secret")
+	}
+	return newConfig.Changes(oldConfig)
+}
 
-	return match[1]
+// endpointOrDefault returns the endpoint provided in cloud.conf under endpointKey,
+// or defaultEndpointValue when it is not set.
+func endpointOrDefault(endpointKey, endpoint, defaultEndpointValue string) string {
+	if endpoint == "" {
+		klog.V(2).Infof("%s is not provided", endpointKey)
+		return defaultEndpointValue
+	}
+	return endpoint
 }
 
-func defaultGetResourceID(resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, error) {
+// defaultGetResourceID looks the ID of resource group resourceName up in Resource Manager.
+// Failed calls are reported as *ibmcloud.APIError, a missing resource group as a plain error.
+func defaultGetResourceID(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+	// The token is requested here rather than by an IamAuthenticator, which ignores ctx.
+	accessToken, err := ibmcloud.RequestIAMToken(ctx, client, apiKey, iamEndpoint)
+	if err != nil {
+		return "", "", err
+	}
 	serviceClientOptions := &resourcemanagerv2.ResourceManagerV2Options{
 		URL:           rmEndpoint,
-		Authenticator: &core.IamAuthenticator{ApiKey: apiKey, URL: iamEndpoint},
+		Authenticator: &core.BearerTokenAuthenticator{BearerToken: accessToken},
 	}
 
 	serviceClient, err := resourcemanagerv2.NewResourceManagerV2UsingExternalConfig(serviceClientOptions)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	serviceClient.Service.SetHTTPClient(client)
 	listResourceGroupsOptions := serviceClient.NewListResourceGroupsOptions()
 	listResourceGroupsOptions.SetAccountID(accountID)
 	listResourceGroupsOptions.SetName(resourceName)
-	resourceGroupList, detailedResponse, err := serviceClient.ListResourceGroups(listResourceGroupsOptions)
 
+	callCtx, cancel := context.WithTimeout(ctx, ibmcloud.CallTimeout)
+	defer cancel()
+	resourceGroupList, detailedResponse, err := serviceClient.ListResourceGroupsWithContext(callCtx, listResourceGroupsOptions)
 	if err != nil {
-		return "", err
+		return "", "", ibmcloud.NewAPIError("list resource groups", detailedResponse, err)
 	}
 
-	if len(resourceGroupList.Resources) > 0 {
-		for _, v := range resourceGroupList.Resources {
-			if v.Name != nil && *v.Name == resourceName {
-				resourceID := *v.ID
-				return resourceID, nil
-			}
+	transactionID := detailedResponse.Headers.Get(ibmcloud.TransactionIDHeader)
+	for _, v := range resourceGroupList.Resources {
+		if v.Name != nil && *v.Name == resourceName && v.ID != nil {
+			return *v.ID, transactionID, nil
 		}
 	}
-	transactionId := detailedResponse.Headers.Get("Transaction-Id")
-	klog.V(2).Infof("%s TransactionId for the resource group %s", transactionId, resourceName)
-
-	return "", fmt.Errorf("Resource group '%s' could not locate at the moment, controller will retry after %s.", resourceName, util.Resync.String())
+	return "", transactionID, fmt.Errorf("resource group %q not found in account %s, transaction ID %q", resourceName, accountID, transactionID)
 }
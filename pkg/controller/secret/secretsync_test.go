@@ -1,17 +1,125 @@
 package secret
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	operatorlisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
 	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
 	k8v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
-func fakeGetResourceID(resourceName, accountID, apiKey, resourceManagerEndpoint, iamEndpoint string) (string, error) {
-	return "fakeid", nil
+func fakeGetResourceID(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, resourceManagerEndpoint, iamEndpoint string) (string, string, error) {
+	return "fakeid", "", nil
+}
+
+// testCloudConf is the cloud.conf of the clusters of the sync tests.
+const testCloudConf = "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n"
+
+// newCloudSecret returns the cloud-credential-operator secret holding apiKey.
+func newCloudSecret(apiKey string) *k8v1.Secret {
+	return &k8v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.CloudCredentialSecretName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string][]byte{cloudSecretKey: []byte(apiKey)},
+	}
+}
+
+// newCloudConf returns the cloud-conf ConfigMap holding conf.
+func newCloudConf(conf string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ConfigMapName,
+			Namespace: util.ConfigMapNamespace,
+		},
+		Data: map[string]string{CloudConfigmapKey: conf},
+	}
+}
+
+// driverConfigToml returns the slclient.toml content for cfg.
+func driverConfigToml(cfg *DriverConfig) string {
+	data, err := cfg.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+// newTestSecretSyncController returns a Managed SecretSyncController backed by a fake clientset.
+// Secrets, ConfigMaps and Infrastructures in objects are also added to the listers.
+func newTestSecretSyncController(getResourceID func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, resourceManagerEndpoint, iamEndpoint string) (string, string, error), objects ...runtime.Object) *SecretSyncController {
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	infraIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	proxyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	cloudCredentialIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	clusterCSIDriverIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var kubeObjects []runtime.Object
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *k8v1.Secret:
+			secretIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *k8v1.ConfigMap:
+			configMapIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *configv1.Infrastructure:
+			infraIndexer.Add(o)
+		case *configv1.Proxy:
+			proxyIndexer.Add(o)
+		case *operatorv1.CloudCredential:
+			cloudCredentialIndexer.Add(o)
+		case *operatorv1.ClusterCSIDriver:
+			clusterCSIDriverIndexer.Add(o)
+		}
+	}
+	return &SecretSyncController{
+		operatorClient: v1helpers.NewFakeOperatorClient(
+			&operatorv1.OperatorSpec{ManagementState: operatorv1.Managed},
+			&operatorv1.OperatorStatus{},
+			nil,
+		),
+		kubeClient:              fake.NewSimpleClientset(kubeObjects...),
+		secretLister:            corelisters.NewSecretLister(secretIndexer),
+		configMapLister:         corelisters.NewConfigMapLister(configMapIndexer),
+		operatorConfigMapLister: corelisters.NewConfigMapLister(configMapIndexer),
+		infraLister:             configlisters.NewInfrastructureLister(infraIndexer),
+		cloudCredentialLister:   operatorlisters.NewCloudCredentialLister(cloudCredentialIndexer),
+		clusterCSIDriverLister:  operatorlisters.NewClusterCSIDriverLister(clusterCSIDriverIndexer),
+		httpClients:             ibmcloud.NewHTTPClientCache(configlisters.NewProxyLister(proxyIndexer), corelisters.NewConfigMapLister(configMapIndexer)),
+		eventRecorder:           events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now())),
+		getResourceID:           getResourceID,
+		validateAPIKey:          func(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error { return nil },
+		missingPermissions: func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, accountID, resourceGroupID string) ([]string, error) {
+			return nil, nil
+		},
+		checkRootKeyUsable: func(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *ibmcloud.CRN) error {
+			return nil
+		},
+		lookupBackoff: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 4},
+		clock:         clocktesting.NewFakePassiveClock(time.Now()),
+	}
 }
 
 func TestTranslateSecretError(t *testing.T) {
@@ -19,9 +127,7 @@ func TestTranslateSecretError(t *testing.T) {
 	secretName := "ibm-cloud-credential"
 	cmNamespace := "test-ns-cco"
 	cmName := "cloud-conf"
-	c := &SecretSyncController{
-		getResourceID: defaultGetResourceID,
-	}
+	c := newTestSecretSyncController(defaultGetResourceID)
 	type args struct {
 		cloudSecret *k8v1.Secret
 		cloudConf   *k8v1.ConfigMap
@@ -139,7 +245,7 @@ func TestTranslateSecretError(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := c.translateSecret(tt.args.cloudSecret, tt.args.cloudConf)
+			got, err := c.translateSecret(context.TODO(), tt.args.cloudSecret, tt.args.cloudConf)
 			if err == nil {
 				t.Errorf("translateSecret() no error returned %v", err)
 				return
@@ -194,7 +300,7 @@ func TestTranslateSecretSuccess(t *testing.T) {
 						Namespace: util.OperatorNamespace,
 					},
 					Type: k8v1.SecretTypeOpaque,
-					Data: map[string][]byte{StorageSecretStoreKey: []byte(fmt.Sprintf(StorageSecretTomlTemplate, "https://private.iam.cloud.ibm.com", "https://eu-de.private.iaas.cloud.ibm.com", "fakeid", apiKey))},
+					Data: map[string][]byte{StorageSecretStoreKey: []byte(driverConfigToml(newAPIKeyDriverConfig("https://private.iam.cloud.ibm.com", "https://eu-de.private.iaas.cloud.ibm.com", "fakeid", apiKey)))},
 				},
 			},
 		}, {
@@ -220,7 +326,7 @@ func TestTranslateSecretSuccess(t *testing.T) {
 						Namespace: util.OperatorNamespace,
 					},
 					Type: k8v1.SecretTypeOpaque,
-					Data: map[string][]byte{StorageSecretStoreKey: []byte(fmt.Sprintf(StorageSecretTomlTemplate, defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "region1"), "fakeid", apiKey))},
+					Data: map[string][]byte{StorageSecretStoreKey: []byte(driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "region1"), "fakeid", apiKey)))},
 				},
 			},
 		}, {
@@ -246,19 +352,17 @@ func TestTranslateSecretSuccess(t *testing.T) {
 						Namespace: util.OperatorNamespace,
 					},
 					Type: k8v1.SecretTypeOpaque,
-					Data: map[string][]byte{StorageSecretStoreKey: []byte(fmt.Sprintf(StorageSecretTomlTemplate, defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "region1"), "fakeid", apiKey))},
+					Data: map[string][]byte{StorageSecretStoreKey: []byte(driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "region1"), "fakeid", apiKey)))},
 				},
 			},
 		},
 	}
 
-	c := &SecretSyncController{
-		getResourceID: fakeGetResourceID,
-	}
+	c := newTestSecretSyncController(fakeGetResourceID)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualSecret, err := c.translateSecret(tt.args.cloudSecret, tt.args.cloudConf)
+			actualSecret, err := c.translateSecret(context.TODO(), tt.args.cloudSecret, tt.args.cloudConf)
 			if err != nil {
 				t.Errorf("translateSecret() error: %v", err)
 			} else if !reflect.DeepEqual(actualSecret, tt.args.expectedSecret) {
@@ -267,3 +371,497 @@ func TestTranslateSecretSuccess(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslateSecretCloudConfVariants(t *testing.T) {
+	apiKey := "testapikey"
+	cloudSecret := newCloudSecret(apiKey)
+	defaultEndpoints := driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), "fakeid", apiKey))
+	privateEndpoints := driverConfigToml(newAPIKeyDriverConfig("https://private.iam.cloud.ibm.com", "https://us-south.private.iaas.cloud.ibm.com", "fakeid", apiKey))
+
+	tests := []struct {
+		name         string
+		conf         string
+		expectedToml string
+	}{
+		{
+			name: "installer generated cloud.conf",
+			conf: `[global]
+version = 1.1.0
+[kubernetes]
+config-file = ""
+[provider]
+accountID = testaccount
+clusterID = mycluster-x8vtp
+cluster-default-provider = g2
+region = us-south
+g2Credentials = /etc/vpc/ibmcloud_api_key
+g2ResourceGroupName = mycluster-rg
+g2VpcName = mycluster-vpc
+g2workerServiceAccountID = testaccount
+g2VpcSubnetNames = mycluster-subnet-control-plane-us-south-1,mycluster-subnet-compute-us-south-1
+`,
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "keys without a section header",
+			conf:         "accountID = testaccount\nregion = us-south\ng2ResourceGroupName = mycluster-rg\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "tab separated keys and values",
+			conf:         "[provider]\n\taccountID\t=\ttestaccount\n\tregion\t=\tus-south\n\tg2ResourceGroupName\t=\tmycluster-rg\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "quoted values",
+			conf:         "[provider]\naccountID = \"testaccount\"\nregion = \"us-south\"\ng2ResourceGroupName = \"mycluster-rg\"\niamEndpointOverride = \"https://private.iam.cloud.ibm.com\"\ng2EndpointOverride = \"https://us-south.private.iaas.cloud.ibm.com\"\n",
+			expectedToml: privateEndpoints,
+		},
+		{
+			name:         "no trailing newline",
+			conf:         "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = mycluster-rg",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "no spaces around equal sign",
+			conf:         "[provider]\naccountID=testaccount\nregion=us-south\ng2ResourceGroupName=mycluster-rg\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "windows line endings",
+			conf:         "[provider]\r\naccountID = testaccount\r\nregion = us-south\r\ng2ResourceGroupName = mycluster-rg\r\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "comments and blank lines",
+			conf:         "# generated by the installer\n\n[provider]\n; account of the cluster\naccountID = testaccount\n\nregion = us-south # inline comment\ng2ResourceGroupName = mycluster-rg\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "provider section after other sections with same keys",
+			conf:         "[global]\nregion = eu-de\n[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = mycluster-rg\n[kubernetes]\nconfig-file = \"\"\n",
+			expectedToml: defaultEndpoints,
+		},
+		{
+			name:         "private endpoint overrides",
+			conf:         "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = mycluster-rg\niamEndpointOverride = https://private.iam.cloud.ibm.com\ng2EndpointOverride = https://us-south.private.iaas.cloud.ibm.com\nrmEndpointOverride = https://private.resource-controller.cloud.ibm.com\n",
+			expectedToml: privateEndpoints,
+		},
+	}
+
+	c := newTestSecretSyncController(fakeGetResourceID)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudConf := newCloudConf(tt.conf)
+			actualSecret, err := c.translateSecret(context.TODO(), cloudSecret, cloudConf)
+			if err != nil {
+				t.Fatalf("translateSecret() error: %v", err)
+			}
+			if actual := string(actualSecret.Data[StorageSecretStoreKey]); actual != tt.expectedToml {
+				t.Errorf("translateSecret() got toml:\n%s\nexpected:\n%s", actual, tt.expectedToml)
+			}
+		})
+	}
+}
+
+func ibmCloudInfrastructure(location, resourceGroupName string, endpoints ...configv1.IBMCloudServiceEndpoint) *configv1.Infrastructure {
+	return &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: infraConfigName,
+		},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.IBMCloudPlatformType,
+				IBMCloud: &configv1.IBMCloudPlatformStatus{
+					Location:          location,
+					ResourceGroupName: resourceGroupName,
+					ServiceEndpoints:  endpoints,
+				},
+			},
+		},
+	}
+}
+
+func TestTranslateSecretInfrastructure(t *testing.T) {
+	apiKey := "testapikey"
+	cloudSecret := newCloudSecret(apiKey)
+	fullConf := "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = conf-rg\niamEndpointOverride = https://conf.iam.cloud.ibm.com\ng2EndpointOverride = https://conf.iaas.cloud.ibm.com\nrmEndpointOverride = https://conf.resource-controller.cloud.ibm.com\n"
+
+	tests := []struct {
+		name                      string
+		conf                      string
+		infra                     *configv1.Infrastructure
+		expectedResourceGroupName string
+		expectedResourceManager   string
+		expectedToml              string
+	}{
+		{
+			name:                      "no infrastructure",
+			conf:                      fullConf,
+			expectedResourceGroupName: "conf-rg",
+			expectedResourceManager:   "https://conf.resource-controller.cloud.ibm.com",
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig("https://conf.iam.cloud.ibm.com", "https://conf.iaas.cloud.ibm.com", "fakeid", apiKey)),
+		},
+		{
+			name: "infrastructure without IBM Cloud status",
+			conf: fullConf,
+			infra: &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: infraConfigName},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{Type: configv1.IBMCloudPlatformType},
+				},
+			},
+			expectedResourceGroupName: "conf-rg",
+			expectedResourceManager:   "https://conf.resource-controller.cloud.ibm.com",
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig("https://conf.iam.cloud.ibm.com", "https://conf.iaas.cloud.ibm.com", "fakeid", apiKey)),
+		},
+		{
+			name: "infrastructure overrides cloud.conf",
+			conf: fullConf,
+			infra: ibmCloudInfrastructure("eu-de", "infra-rg",
+				configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceIAM, URL: "https://private.iam.cloud.ibm.com"},
+				configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceVPC, URL: "https://eu-de.private.iaas.cloud.ibm.com/v1"},
+				configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceResourceManager, URL: "https://private.resource-controller.cloud.ibm.com"},
+				configv1.IBMCloudServiceEndpoint{Name: configv1.IBMCloudServiceCOS, URL: "https://s3.direct.eu-de.cloud-object-storage.appdomain.cloud"},
+			),
+			expectedResourceGroupName: "infra-rg",
+			expectedResourceManager:   "https://private.resource-controller.cloud.ibm.com",
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig("https://private.iam.cloud.ibm.com", "https://eu-de.private.iaas.cloud.ibm.com/v1", "fakeid", apiKey)),
+		},
+		{
+			name:                      "infrastructure without endpoints falls back to cloud.conf endpoints",
+			conf:                      fullConf,
+			infra:                     ibmCloudInfrastructure("us-south", "infra-rg"),
+			expectedResourceGroupName: "infra-rg",
+			expectedResourceManager:   "https://conf.resource-controller.cloud.ibm.com",
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig("https://conf.iam.cloud.ibm.com", "https://conf.iaas.cloud.ibm.com", "fakeid", apiKey)),
+		},
+		{
+			name:                      "infrastructure provides values missing in cloud.conf",
+			conf:                      "[provider]\naccountID = testaccount\n",
+			infra:                     ibmCloudInfrastructure("jp-tok", "infra-rg"),
+			expectedResourceGroupName: "infra-rg",
+			expectedResourceManager:   defaultResourceManagerEndpoint,
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "jp-tok"), "fakeid", apiKey)),
+		},
+		{
+			name:                      "infrastructure resource group replaces the cloud.conf resource group ID",
+			conf:                      "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = conf-rg\ng2ResourceGroupID = 0123456789abcdef0123456789abcdef\n",
+			infra:                     ibmCloudInfrastructure("us-south", "infra-rg"),
+			expectedResourceGroupName: "infra-rg",
+			expectedResourceManager:   defaultResourceManagerEndpoint,
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), "fakeid", apiKey)),
+		},
+		{
+			name:                      "infrastructure naming the cloud.conf resource group keeps its ID",
+			conf:                      "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = conf-rg\ng2ResourceGroupID = 0123456789abcdef0123456789abcdef\n",
+			infra:                     ibmCloudInfrastructure("us-south", "conf-rg"),
+			expectedResourceGroupName: "",
+			expectedResourceManager:   "",
+			expectedToml:              driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), "0123456789abcdef0123456789abcdef", apiKey)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resourceGroupName, resourceManagerEndpoint string
+			var objects []runtime.Object
+			if tt.infra != nil {
+				objects = append(objects, tt.infra)
+			}
+			c := newTestSecretSyncController(func(ctx context.Context, client *http.Client, name, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+				resourceGroupName, resourceManagerEndpoint = name, rmEndpoint
+				return "fakeid", "", nil
+			}, objects...)
+			cloudConf := newCloudConf(tt.conf)
+			actualSecret, err := c.translateSecret(context.TODO(), cloudSecret, cloudConf)
+			if err != nil {
+				t.Fatalf("translateSecret() error: %v", err)
+			}
+			if actual := string(actualSecret.Data[StorageSecretStoreKey]); actual != tt.expectedToml {
+				t.Errorf("translateSecret() got toml:\n%s\nexpected:\n%s", actual, tt.expectedToml)
+			}
+			if resourceGroupName != tt.expectedResourceGroupName {
+				t.Errorf("translateSecret() looked up resource group %q, expected %q", resourceGroupName, tt.expectedResourceGroupName)
+			}
+			if resourceManagerEndpoint != tt.expectedResourceManager {
+				t.Errorf("translateSecret() used resource manager %q, expected %q", resourceManagerEndpoint, tt.expectedResourceManager)
+			}
+		})
+	}
+}
+
+func TestTranslateSecretResourceGroupID(t *testing.T) {
+	apiKey := "testapikey"
+	confID := "0123456789abcdef0123456789abcdef"
+	overrideID := "fedcba9876543210fedcba9876543210"
+	cloudSecret := newCloudSecret(apiKey)
+	operatorConfig := func(id string) *k8v1.ConfigMap {
+		return &k8v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      util.OperatorConfigMapName,
+				Namespace: util.OperatorNamespace,
+			},
+			Data: map[string]string{resourceGroupIDConfigKey: id},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		conf           string
+		operatorConfig *k8v1.ConfigMap
+		expectedLookup bool
+		expectedID     string
+		expectError    bool
+	}{
+		{
+			name:           "resource group looked up by name",
+			conf:           "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n",
+			expectedLookup: true,
+			expectedID:     "fakeid",
+		},
+		{
+			name:       "resource group ID from cloud.conf",
+			conf:       "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupID = " + confID + "\n",
+			expectedID: confID,
+		},
+		{
+			name:       "resource group ID from cloud.conf without account",
+			conf:       "[provider]\nregion = us-south\ng2ResourceGroupID = " + confID + "\n",
+			expectedID: confID,
+		},
+		{
+			name:           "resource group ID from operator config without account",
+			conf:           "[provider]\nregion = us-south\n",
+			operatorConfig: operatorConfig(overrideID),
+			expectedID:     overrideID,
+		},
+		{
+			name:        "no account without resource group ID",
+			conf:        "[provider]\nregion = us-south\ng2ResourceGroupName = testresource\n",
+			expectError: true,
+		},
+		{
+			name:       "resource group ID from cloud.conf with name",
+			conf:       "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\ng2ResourceGroupID = " + confID + "\n",
+			expectedID: confID,
+		},
+		{
+			name:           "resource group ID from operator config",
+			conf:           "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n",
+			operatorConfig: operatorConfig(overrideID),
+			expectedID:     overrideID,
+		},
+		{
+			name:           "operator config takes precedence over cloud.conf",
+			conf:           "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupID = " + confID + "\n",
+			operatorConfig: operatorConfig(overrideID),
+			expectedID:     overrideID,
+		},
+		{
+			name:           "empty operator config value is ignored",
+			conf:           "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n",
+			operatorConfig: operatorConfig(""),
+			expectedLookup: true,
+			expectedID:     "fakeid",
+		},
+		{
+			name:        "invalid resource group ID in cloud.conf",
+			conf:        "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupID = Default\n",
+			expectError: true,
+		},
+		{
+			name:           "invalid resource group ID in operator config",
+			conf:           "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n",
+			operatorConfig: operatorConfig("testresource"),
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.operatorConfig != nil {
+				objects = append(objects, tt.operatorConfig)
+			}
+			lookedUp := false
+			c := newTestSecretSyncController(func(ctx context.Context, client *http.Client, resourceName, accountID, apiKey, rmEndpoint, iamEndpoint string) (string, string, error) {
+				lookedUp = true
+				return "fakeid", "", nil
+			}, objects...)
+			cloudConf := newCloudConf(tt.conf)
+
+			actualSecret, err := c.translateSecret(context.TODO(), cloudSecret, cloudConf)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("translateSecret() expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("translateSecret() error: %v", err)
+			}
+			if lookedUp != tt.expectedLookup {
+				t.Errorf("translateSecret() looked up resource group: %v, expected %v", lookedUp, tt.expectedLookup)
+			}
+			expectedToml := driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), tt.expectedID, apiKey))
+			if actual := string(actualSecret.Data[StorageSecretStoreKey]); actual != expectedToml {
+				t.Errorf("translateSecret() got toml:\n%s\nexpected:\n%s", actual, expectedToml)
+			}
+		})
+	}
+}
+
+func TestTranslateSecretTrustedProfile(t *testing.T) {
+	profileID := "Profile-9f1c3b8e-2d4a-4c55-8f3e-1a2b3c4d5e6f"
+	resourceGroupID := "0123456789abcdef0123456789abcdef"
+	tokenPath := ComputeResourceTokenDir + "/" + ComputeResourceTokenFile
+	confWithID := "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\ng2ResourceGroupID = " + resourceGroupID + "\n"
+	confWithoutID := "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n"
+	operatorConfig := func(data map[string]string) *k8v1.ConfigMap {
+		return &k8v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      util.OperatorConfigMapName,
+				Namespace: util.OperatorNamespace,
+			},
+			Data: data,
+		}
+	}
+
+	tests := []struct {
+		name             string
+		secretData       map[string][]byte
+		conf             string
+		objects          []runtime.Object
+		expectedToml     string
+		expectValidation bool
+		expectError      bool
+	}{
+		{
+			name:             "API key",
+			secretData:       map[string][]byte{cloudSecretKey: []byte("testapikey")},
+			conf:             confWithID,
+			expectedToml:     driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), resourceGroupID, "testapikey")),
+			expectValidation: true,
+		},
+		{
+			name:         "trusted profile in cloud-credential-operator secret",
+			secretData:   map[string][]byte{trustedProfileSecretKey: []byte(profileID)},
+			conf:         confWithID,
+			expectedToml: driverConfigToml(newTrustedProfileDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), resourceGroupID, profileID, tokenPath)),
+		},
+		{
+			name:         "trusted profile takes precedence over API key",
+			secretData:   map[string][]byte{cloudSecretKey: []byte("testapikey"), trustedProfileSecretKey: []byte(profileID)},
+			conf:         confWithID,
+			expectedToml: driverConfigToml(newTrustedProfileDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), resourceGroupID, profileID, tokenPath)),
+		},
+		{
+			name:         "trusted profile in operator config",
+			secretData:   map[string][]byte{cloudSecretKey: []byte("testapikey")},
+			conf:         confWithID,
+			objects:      []runtime.Object{operatorConfig(map[string]string{trustedProfileConfigKey: profileID})},
+			expectedToml: driverConfigToml(newTrustedProfileDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), resourceGroupID, profileID, tokenPath)),
+		},
+		{
+			name:         "trusted profile with cached resource group ID",
+			secretData:   map[string][]byte{trustedProfileSecretKey: []byte(profileID)},
+			conf:         confWithoutID,
+			objects:      []runtime.Object{resourceGroupCache("testaccount", "testresource", resourceGroupID)},
+			expectedToml: driverConfigToml(newTrustedProfileDriverConfig(defaultTokenExchangeURL, fmt.Sprintf(defaultRIAASEndpointURL, "us-south"), resourceGroupID, profileID, tokenPath)),
+		},
+		{
+			name:        "trusted profile without resource group ID",
+			secretData:  map[string][]byte{trustedProfileSecretKey: []byte(profileID)},
+			conf:        confWithoutID,
+			expectError: true,
+		},
+		{
+			name:        "invalid trusted profile ID",
+			secretData:  map[string][]byte{trustedProfileSecretKey: []byte("my-profile")},
+			conf:        confWithID,
+			expectError: true,
+		},
+		{
+			name:        "neither API key nor trusted profile",
+			secretData:  map[string][]byte{},
+			conf:        confWithID,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestSecretSyncController(fakeGetResourceID, tt.objects...)
+			validated := false
+			c.validateAPIKey = func(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) error {
+				validated = true
+				return nil
+			}
+			cloudSecret := &k8v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      util.CloudCredentialSecretName,
+					Namespace: util.OperatorNamespace,
+				},
+				Data: tt.secretData,
+			}
+			cloudConf := newCloudConf(tt.conf)
+
+			actualSecret, err := c.translateSecret(context.TODO(), cloudSecret, cloudConf)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("translateSecret() expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("translateSecret() error: %v", err)
+			}
+			if actual := string(actualSecret.Data[StorageSecretStoreKey]); actual != tt.expectedToml {
+				t.Errorf("translateSecret() got toml:\n%s\nexpected:\n%s", actual, tt.expectedToml)
+			}
+			if validated != tt.expectValidation {
+				t.Errorf("translateSecret() validated API key: %v, expected %v", validated, tt.expectValidation)
+			}
+		})
+	}
+}
+
+func TestSyncChangeEvent(t *testing.T) {
+	publishedSecret := &k8v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.IBMCSIDriverSecretName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string][]byte{StorageSecretStoreKey: []byte(driverConfigToml(newAPIKeyDriverConfig(defaultTokenExchangeURL, "https://us-south.iaas.cloud.ibm.com", "fakeid", "oldapikey")))},
+	}
+	cloudSecret := newCloudSecret("newapikey")
+	cloudConf := newCloudConf("[provider]\naccountID = testaccount\nregion = eu-de\ng2ResourceGroupName = testresource\n")
+
+	c := newTestSecretSyncController(fakeGetResourceID, publishedSecret, cloudSecret, cloudConf)
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+
+	var message string
+	for _, event := range c.eventRecorder.(events.InMemoryRecorder).Events() {
+		if event.Reason == "StorageSecretStoreChanged" {
+			message = event.Message
+		}
+	}
+	if message == "" {
+		t.Fatalf("sync() did not emit a StorageSecretStoreChanged event")
+	}
+	for _, expected := range []string{
+		`g2_riaas_endpoint_url: "https://us-south.iaas.cloud.ibm.com" -> "https://eu-de.iaas.cloud.ibm.com"`,
+		"g2_api_key: <redacted> -> <redacted>",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("event message %q does not contain %q", message, expected)
+		}
+	}
+	if strings.Contains(message, "apikey") {
+		t.Errorf("event message %q leaked the API key", message)
+	}
+}
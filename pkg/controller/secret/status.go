@@ -0,0 +1,124 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys of the status ConfigMap.
+const (
+	statusRegionKey                  = "region"
+	statusResourceGroupNameKey       = "resourceGroupName"
+	statusResourceGroupIDKey         = "resourceGroupID"
+	statusIAMEndpointKey             = "iamEndpoint"
+	statusRIAASEndpointKey           = "riaasEndpoint"
+	statusResourceManagerEndpointKey = "resourceManagerEndpoint"
+	statusCredentialsSourceKey       = "credentialsSource"
+	statusAuthenticationKey          = "authentication"
+	statusTrustedProfileIDKey        = "trustedProfileID"
+	statusLastSuccessfulSyncKey      = "lastSuccessfulSync"
+
+	authenticationAPIKey         = "APIKey"
+	authenticationTrustedProfile = "TrustedProfile"
+)
+
+// resolvedConfig is the configuration of the driver chosen by the operator, with the
+// inputs that are not part of slclient.toml.
+type resolvedConfig struct {
+	credentialsSecret       *v1.Secret
+	region                  string
+	accountID               string
+	resourceGroupName       string
+	resourceManagerEndpoint string
+	driverConfig            *DriverConfig
+}
+
+// driverSecret returns the storage-secret-store secret for the configuration.
+func (r *resolvedConfig) driverSecret() (*v1.Secret, error) {
+	tomlData, err := r.driverConfig.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.IBMCSIDriverSecretName,
+			Namespace: util.OperatorNamespace,
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{StorageSecretStoreKey: tomlData},
+	}, nil
+}
+
+// statusData returns the non-secret summary of the configuration published in the status
+// ConfigMap. The API key is deliberately not part of it.
+func (r *resolvedConfig) statusData(lastSync time.Time) map[string]string {
+	vpc := r.driverConfig.VPC
+	data := map[string]string{
+		statusRegionKey:                  r.region,
+		statusResourceGroupNameKey:       r.resourceGroupName,
+		statusResourceGroupIDKey:         vpc.G2ResourceGroupID,
+		statusIAMEndpointKey:             vpc.G2TokenExchangeEndpointURL,
+		statusRIAASEndpointKey:           vpc.G2RIAASEndpointURL,
+		statusResourceManagerEndpointKey: r.resourceManagerEndpoint,
+		statusCredentialsSourceKey:       fmt.Sprintf("secret %s/%s", r.credentialsSecret.Namespace, r.credentialsSecret.Name),
+		statusAuthenticationKey:          authenticationAPIKey,
+		statusLastSuccessfulSyncKey:      lastSync.UTC().Format(time.RFC3339),
+	}
+	if vpc.IAMProfileID != "" {
+		data[statusAuthenticationKey] = authenticationTrustedProfile
+		data[statusTrustedProfileIDKey] = vpc.IAMProfileID
+	}
+	return data
+}
+
+// publishStatus writes the summary of resolved to the status ConfigMap. Syncs with the same
+// configuration only refresh the time of the last successful sync once per resync interval,
+// so that the frequent syncs triggered by informer events don't rewrite the ConfigMap.
+func (c *SecretSyncController) publishStatus(ctx context.Context, resolved *resolvedConfig) error {
+	now := c.clock.Now()
+	data := resolved.statusData(now)
+	existing, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.StatusConfigMapName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && sameStatus(existing.Data, data) && !statusSyncOutdated(existing.Data, now) {
+		return nil
+	}
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), c.eventRecorder, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.StatusConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: data,
+	})
+	return err
+}
+
+// sameStatus returns whether the status ConfigMap data existing and required only differ
+// in the time of the last successful sync.
+func sameStatus(existing, required map[string]string) bool {
+	if len(existing) != len(required) {
+		return false
+	}
+	for key, value := range required {
+		existingValue, ok := existing[key]
+		if !ok || (key != statusLastSuccessfulSyncKey && existingValue != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// statusSyncOutdated returns whether the time of the last successful sync in the status
+// ConfigMap data existing is missing, invalid or at least one resync interval before now.
+func statusSyncOutdated(existing map[string]string, now time.Time) bool {
+	lastSync, err := time.Parse(time.RFC3339, existing[statusLastSuccessfulSyncKey])
+	return err != nil || now.Sub(lastSync) >= util.Resync
+}
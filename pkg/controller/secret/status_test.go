@@ -0,0 +1,140 @@
+package secret
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSyncPublishesStatus(t *testing.T) {
+	cloudConf := newCloudConf("[provider]\naccountID = testaccount\nregion = eu-de\ng2ResourceGroupName = testresource\nrmEndpointOverride = https://private.resource-controller.cloud.ibm.com\n")
+	syncTime := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		secretData   map[string][]byte
+		objects      []runtime.Object
+		expectedData map[string]string
+	}{
+		{
+			name:       "API key",
+			secretData: map[string][]byte{cloudSecretKey: []byte("secretapikey")},
+			expectedData: map[string]string{
+				statusRegionKey:                  "eu-de",
+				statusResourceGroupNameKey:       "testresource",
+				statusResourceGroupIDKey:         "fakeid",
+				statusIAMEndpointKey:             defaultTokenExchangeURL,
+				statusRIAASEndpointKey:           "https://eu-de.iaas.cloud.ibm.com",
+				statusResourceManagerEndpointKey: "https://private.resource-controller.cloud.ibm.com",
+				statusCredentialsSourceKey:       "secret " + util.OperatorNamespace + "/" + util.CloudCredentialSecretName,
+				statusAuthenticationKey:          authenticationAPIKey,
+				statusLastSuccessfulSyncKey:      "2026-10-18T12:30:00Z",
+			},
+		},
+		{
+			name:       "trusted profile",
+			secretData: map[string][]byte{cloudSecretKey: []byte("secretapikey"), trustedProfileSecretKey: []byte("Profile-1234")},
+			objects:    []runtime.Object{resourceGroupCache("testaccount", "testresource", "cachedid")},
+			expectedData: map[string]string{
+				statusRegionKey:                  "eu-de",
+				statusResourceGroupNameKey:       "testresource",
+				statusResourceGroupIDKey:         "cachedid",
+				statusIAMEndpointKey:             defaultTokenExchangeURL,
+				statusRIAASEndpointKey:           "https://eu-de.iaas.cloud.ibm.com",
+				statusResourceManagerEndpointKey: "https://private.resource-controller.cloud.ibm.com",
+				statusCredentialsSourceKey:       "secret " + util.OperatorNamespace + "/" + util.CloudCredentialSecretName,
+				statusAuthenticationKey:          authenticationTrustedProfile,
+				statusTrustedProfileIDKey:        "Profile-1234",
+				statusLastSuccessfulSyncKey:      "2026-10-18T12:30:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudSecret := &k8v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      util.CloudCredentialSecretName,
+					Namespace: util.OperatorNamespace,
+				},
+				Data: tt.secretData,
+			}
+			c := newTestSecretSyncController(fakeGetResourceID, append(tt.objects, cloudSecret, cloudConf)...)
+			c.clock = clocktesting.NewFakePassiveClock(syncTime)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+
+			status, err := c.kubeClient.CoreV1().ConfigMaps(util.OperatorNamespace).Get(context.TODO(), util.StatusConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", util.StatusConfigMapName, err)
+			}
+			if !reflect.DeepEqual(status.Data, tt.expectedData) {
+				t.Errorf("status got %v, expected %v", status.Data, tt.expectedData)
+			}
+			for key, value := range status.Data {
+				if strings.Contains(value, "secretapikey") {
+					t.Errorf("status key %s leaked the API key", key)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncStatusUnchanged(t *testing.T) {
+	cloudConf := newCloudConf("[provider]\naccountID = testaccount\nregion = eu-de\ng2ResourceGroupName = testresource\n")
+	cloudSecret := newCloudSecret("secretapikey")
+	firstSync := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	c := newTestSecretSyncController(fakeGetResourceID, cloudSecret, cloudConf)
+	clock := clocktesting.NewFakePassiveClock(firstSync)
+	c.clock = clock
+	syncCtx := factory.NewSyncContext("test", c.eventRecorder)
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	status, err := c.kubeClient.CoreV1().ConfigMaps(util.OperatorNamespace).Get(context.TODO(), util.StatusConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", util.StatusConfigMapName, err)
+	}
+	// The informer of the operator namespace sees the status ConfigMap of the first sync.
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(status)
+	c.operatorConfigMapLister = corelisters.NewConfigMapLister(indexer)
+
+	kubeClient := c.kubeClient.(*fake.Clientset)
+	kubeClient.ClearActions()
+	clock.SetTime(firstSync.Add(util.Resync / 2))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	for _, action := range kubeClient.Actions() {
+		if action.GetResource().Resource == "configmaps" && action.GetVerb() != "get" && action.GetVerb() != "list" {
+			t.Errorf("sync() with the same configuration within the resync interval unexpectedly wrote configmaps: %s", action.GetVerb())
+		}
+	}
+
+	// Once per resync interval, the time of the last successful sync is refreshed.
+	clock.SetTime(firstSync.Add(util.Resync))
+	if err := c.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	status, err = c.kubeClient.CoreV1().ConfigMaps(util.OperatorNamespace).Get(context.TODO(), util.StatusConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", util.StatusConfigMapName, err)
+	}
+	if expected := "2026-10-18T12:50:00Z"; status.Data[statusLastSuccessfulSyncKey] != expected {
+		t.Errorf("status %s got %q, expected %q", statusLastSuccessfulSyncKey, status.Data[statusLastSuccessfulSyncKey], expected)
+	}
+}
@@ -0,0 +1,66 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	// Name of key in Secret provided by cloud-credentials-operator with the ID of an IAM trusted profile.
+	// When set, the driver authenticates with a compute resource token instead of an API key.
+	trustedProfileSecretKey = "ibmcloud_trusted_profile_id"
+	// Name of key in the operator config ConfigMap with the ID of an IAM trusted profile. It takes
+	// precedence over the one in the cloud-credential-operator secret.
+	trustedProfileConfigKey = "trustedProfileID"
+	// trustedProfileIDPrefix is the prefix of all IAM trusted profile IDs.
+	trustedProfileIDPrefix = "Profile-"
+
+	// Directory and file name of the projected service account token that the driver exchanges
+	// for a compute resource token. The path is one of the defaults of the IBM Cloud SDK
+	// container authenticator.
+	ComputeResourceTokenDir  = "/var/run/secrets/tokens"
+	ComputeResourceTokenFile = "vault-token"
+	// ComputeResourceTokenAudience is the audience IAM expects in the service account token.
+	ComputeResourceTokenAudience = "iam"
+)
+
+// TrustedProfileID returns the IAM trusted profile the driver must use, or an empty
+// string when the driver uses the API key from the credentials secret. Both
+// credentialsSecret and operatorConfig may be nil.
+func TrustedProfileID(credentialsSecret *v1.Secret, operatorConfig *v1.ConfigMap) (string, error) {
+	source := fmt.Sprintf("configmap %s key %s", util.OperatorConfigMapName, trustedProfileConfigKey)
+	var profileID string
+	if operatorConfig != nil {
+		profileID = strings.TrimSpace(operatorConfig.Data[trustedProfileConfigKey])
+	}
+	if profileID == "" && credentialsSecret != nil {
+		source = fmt.Sprintf("secret %s key %s", credentialsSecret.Name, trustedProfileSecretKey)
+		profileID = strings.TrimSpace(string(credentialsSecret.Data[trustedProfileSecretKey]))
+	}
+	if profileID == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(profileID, trustedProfileIDPrefix) {
+		return "", fmt.Errorf("%s is not a valid trusted profile ID: %q", source, profileID)
+	}
+	return profileID, nil
+}
+
+// GetTrustedProfileID is TrustedProfileID for the credentials secret and the operator
+// config ConfigMap found by the listers in the operator namespace.
+func GetTrustedProfileID(secretLister corelisters.SecretLister, configMapLister corelisters.ConfigMapLister) (string, error) {
+	credentialsSecret, err := GetCredentialsSecret(secretLister)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	operatorConfig, err := configMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	return TrustedProfileID(credentialsSecret, operatorConfig)
+}
@@ -0,0 +1,70 @@
+package storageclass
+
+import (
+	"context"
+	"fmt"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	operatorlisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/kubernetes"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+)
+
+// storageClassApplier applies StorageClasses the way the StorageClass controller of
+// library-go applies the built-in ones: through the StorageClass hooks, preserving the
+// default StorageClass chosen by the admin and following the StorageClassState of the
+// ClusterCSIDriver.
+type storageClassApplier struct {
+	storageClassLister storagelisters.StorageClassLister
+	scStateEvaluator   *csistorageclasscontroller.StorageClassStateEvaluator
+	hooks              []csistorageclasscontroller.StorageClassHookFunc
+}
+
+func newStorageClassApplier(
+	kubeClient kubernetes.Interface,
+	storageClassLister storagelisters.StorageClassLister,
+	clusterCSIDriverLister operatorlisters.ClusterCSIDriverLister,
+	eventRecorder events.Recorder,
+	hooks []csistorageclasscontroller.StorageClassHookFunc) *storageClassApplier {
+	return &storageClassApplier{
+		storageClassLister: storageClassLister,
+		scStateEvaluator:   csistorageclasscontroller.NewStorageClassStateEvaluator(kubeClient, clusterCSIDriverLister, eventRecorder),
+		hooks:              hooks,
+	}
+}
+
+// apply runs the hooks on expectedSC and applies it.
+func (a *storageClassApplier) apply(ctx context.Context, opSpec *operatorv1.OperatorSpec, expectedSC *storagev1.StorageClass) error {
+	for i := range a.hooks {
+		if err := a.hooks[i](opSpec, expectedSC); err != nil {
+			return fmt.Errorf("error running hook function (index=%d): %w", i, err)
+		}
+	}
+	if err := csistorageclasscontroller.SetDefaultStorageClass(a.storageClassLister, expectedSC); err != nil {
+		return err
+	}
+	return a.scStateEvaluator.EvalAndApplyStorageClass(ctx, expectedSC)
+}
+
+// managed returns true when the StorageClasses of provisioner are managed by the operator.
+func (a *storageClassApplier) managed(provisioner string) bool {
+	return a.scStateEvaluator.IsManaged(a.scStateEvaluator.GetStorageClassState(provisioner))
+}
+
+// AssetNames returns the names of the StorageClasses in the asset files.
+func AssetNames(assetFunc resourceapply.AssetFunc, files []string) ([]string, error) {
+	var names []string
+	for _, file := range files {
+		scBytes, err := assetFunc(file)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, resourceread.ReadStorageClassV1OrDie(scBytes).Name)
+	}
+	return names, nil
+}
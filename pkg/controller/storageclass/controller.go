@@ -0,0 +1,175 @@
+package storageclass
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	operatorinformers "github.com/openshift/client-go/operator/informers/externalversions"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/secret"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// appLabel marks the StorageClasses shipped with the operator.
+	appLabel      = "app"
+	appLabelValue = "ibm-vpc-block-csi-driver"
+)
+
+// This ProfileStorageClassController manages the StorageClasses of the block volume profiles
+// that are not offered in all regions. The StorageClass of a profile is applied like the
+// other StorageClasses of the driver when the profile is available in the cluster region,
+// and removed when it is not.
+type ProfileStorageClassController struct {
+	operatorClient          v1helpers.OperatorClient
+	kubeClient              kubernetes.Interface
+	storageClassLister      storagelisters.StorageClassLister
+	configMapLister         corelisters.ConfigMapLister
+	operatorConfigMapLister corelisters.ConfigMapLister
+	infraLister             configlisters.InfrastructureLister
+	eventRecorder           events.Recorder
+	applier                 *storageClassApplier
+	assetFunc               resourceapply.AssetFunc
+	profiles                []Profile
+}
+
+func NewProfileStorageClassController(
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubernetes.Interface,
+	informers v1helpers.KubeInformersForNamespaces,
+	configInformers configinformers.SharedInformerFactory,
+	operatorInformers operatorinformers.SharedInformerFactory,
+	assetFunc resourceapply.AssetFunc,
+	profiles []Profile,
+	resync time.Duration,
+	eventRecorder events.Recorder,
+	hooks ...csistorageclasscontroller.StorageClassHookFunc) factory.Controller {
+
+	storageClassInformer := informers.InformersFor("").Storage().V1().StorageClasses()
+	clusterCSIDriverLister := operatorInformers.Operator().V1().ClusterCSIDrivers().Lister()
+	operatorConfigMapInformer := informers.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps()
+	configMapInformer := informers.InformersFor(util.ConfigMapNamespace).Core().V1().ConfigMaps()
+	c := &ProfileStorageClassController{
+		operatorClient:          operatorClient,
+		kubeClient:              kubeClient,
+		storageClassLister:      storageClassInformer.Lister(),
+		configMapLister:         configMapInformer.Lister(),
+		operatorConfigMapLister: operatorConfigMapInformer.Lister(),
+		infraLister:             configInformers.Config().V1().Infrastructures().Lister(),
+		eventRecorder:           eventRecorder.WithComponentSuffix("ProfileStorageClass"),
+		applier:                 newStorageClassApplier(kubeClient, storageClassInformer.Lister(), clusterCSIDriverLister, eventRecorder, hooks),
+		assetFunc:               assetFunc,
+		profiles:                profiles,
+	}
+	return factory.New().WithSync(c.sync).ResyncEvery(resync).WithSyncDegradedOnError(operatorClient).WithInformers(
+		operatorClient.Informer(),
+		storageClassInformer.Informer(),
+		configInformers.Config().V1().Infrastructures().Informer(),
+		operatorInformers.Operator().V1().ClusterCSIDrivers().Informer(),
+	).WithFilteredEventsInformers(
+		factory.NamesFilter(util.OperatorConfigMapName),
+		operatorConfigMapInformer.Informer(),
+	).WithFilteredEventsInformers(
+		factory.NamesFilter(util.ConfigMapName),
+		configMapInformer.Informer(),
+	).ToController("ProfileStorageClass", eventRecorder)
+}
+
+func (c *ProfileStorageClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while getting operator state")
+		return err
+	}
+	if opSpec.ManagementState != operatorv1.Managed {
+		klog.V(2).Info("Operator management state is not managed")
+		return nil
+	}
+
+	region, err := c.region()
+	if err != nil {
+		return err
+	}
+	if region == "" {
+		// SecretSync reports the missing cloud-conf.
+		klog.V(2).Infof("Waiting for the cluster region to install the profile StorageClasses")
+		return nil
+	}
+
+	operatorConfig, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		operatorConfig = nil
+	}
+
+	for i := range c.profiles {
+		profile := &c.profiles[i]
+		scBytes, err := c.assetFunc(profile.File)
+		if err != nil {
+			return err
+		}
+		expectedSC := resourceread.ReadStorageClassV1OrDie(scBytes)
+		if profile.availableIn(region, operatorConfig) {
+			if err := c.applier.apply(ctx, opSpec, expectedSC); err != nil {
+				return fmt.Errorf("failed to apply StorageClass %s: %w", expectedSC.Name, err)
+			}
+			continue
+		}
+		klog.V(4).Infof("Profile %s is not available in region %s", profile.Profile, region)
+		if err := c.removeStorageClass(ctx, expectedSC, profile, region); err != nil {
+			return fmt.Errorf("failed to remove StorageClass %s: %w", expectedSC.Name, err)
+		}
+	}
+	return nil
+}
+
+// region returns the region of the cluster, from the Infrastructure object or cloud-conf,
+// or an empty string when neither is available yet.
+func (c *ProfileStorageClassController) region() (string, error) {
+	cloudConfig, err := secret.ClusterCloudConfig(c.configMapLister, c.infraLister)
+	if err != nil {
+		return "", err
+	}
+	return cloudConfig.Provider.Region, nil
+}
+
+// removeStorageClass deletes the StorageClass of profile when it was installed by the
+// operator, e.g. before the profile was withdrawn from region. StorageClasses created by
+// the admin under the same name and unmanaged StorageClasses are left alone.
+func (c *ProfileStorageClassController) removeStorageClass(ctx context.Context, expectedSC *storagev1.StorageClass, profile *Profile, region string) error {
+	existing, err := c.storageClassLister.Get(expectedSC.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if existing.Provisioner != expectedSC.Provisioner || existing.Labels[appLabel] != appLabelValue {
+		return nil
+	}
+	if !c.applier.managed(expectedSC.Provisioner) {
+		return nil
+	}
+	if _, _, err := resourceapply.DeleteStorageClass(ctx, c.kubeClient.StorageV1(), c.eventRecorder, expectedSC); err != nil {
+		return err
+	}
+	c.eventRecorder.Eventf("ProfileStorageClassRemoved", "Removed StorageClass %s, profile %s is not available in region %s", expectedSC.Name, profile.Profile, region)
+	return nil
+}
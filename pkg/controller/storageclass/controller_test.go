@@ -0,0 +1,236 @@
+package storageclass
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	operatorlisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/assets"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+const (
+	generalPurposeStorageClass = "ibmc-vpc-block-general-purpose"
+	sdpStorageClass            = "ibmc-vpc-block-sdp"
+)
+
+func newTestProfileStorageClassController(t *testing.T, objects ...runtime.Object) *ProfileStorageClassController {
+	profilesAsset, err := assets.ReadFile("storageclass_profiles.yaml")
+	if err != nil {
+		t.Fatalf("failed to read the profiles asset: %v", err)
+	}
+	profiles, err := ParseProfiles(profilesAsset)
+	if err != nil {
+		t.Fatalf("failed to parse the profiles asset: %v", err)
+	}
+
+	storageClassIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	infraIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	clusterCSIDriverIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var kubeObjects []runtime.Object
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *storagev1.StorageClass:
+			storageClassIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *k8v1.ConfigMap:
+			configMapIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *configv1.Infrastructure:
+			infraIndexer.Add(o)
+		case *operatorv1.ClusterCSIDriver:
+			clusterCSIDriverIndexer.Add(o)
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(kubeObjects...)
+	eventRecorder := events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now()))
+	return &ProfileStorageClassController{
+		operatorClient: v1helpers.NewFakeOperatorClient(
+			&operatorv1.OperatorSpec{ManagementState: operatorv1.Managed},
+			&operatorv1.OperatorStatus{},
+			nil,
+		),
+		kubeClient:              kubeClient,
+		storageClassLister:      storagelisters.NewStorageClassLister(storageClassIndexer),
+		configMapLister:         corelisters.NewConfigMapLister(configMapIndexer),
+		operatorConfigMapLister: corelisters.NewConfigMapLister(configMapIndexer),
+		infraLister:             configlisters.NewInfrastructureLister(infraIndexer),
+		eventRecorder:           eventRecorder,
+		applier: newStorageClassApplier(
+			kubeClient,
+			storagelisters.NewStorageClassLister(storageClassIndexer),
+			operatorlisters.NewClusterCSIDriverLister(clusterCSIDriverIndexer),
+			eventRecorder,
+			nil,
+		),
+		assetFunc: assets.ReadFile,
+		profiles:  profiles,
+	}
+}
+
+func cloudConf(region string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ConfigMapName,
+			Namespace: util.ConfigMapNamespace,
+		},
+		Data: map[string]string{"cloud.conf": "[provider]\naccountID = testaccount\nregion = " + region + "\ng2ResourceGroupName = testresource\n"},
+	}
+}
+
+func TestSyncProfileStorageClasses(t *testing.T) {
+	installedSDP := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sdpStorageClass,
+			Labels: map[string]string{appLabel: appLabelValue},
+		},
+		Provisioner: "vpc.block.csi.ibm.io",
+	}
+	adminSDP := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: sdpStorageClass},
+		Provisioner: "vpc.block.csi.ibm.io",
+	}
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		expected map[string]bool
+	}{
+		{
+			name:     "no region yet",
+			expected: map[string]bool{generalPurposeStorageClass: false, sdpStorageClass: false},
+		},
+		{
+			name:     "region with sdp",
+			objects:  []runtime.Object{cloudConf("us-south")},
+			expected: map[string]bool{generalPurposeStorageClass: true, sdpStorageClass: true},
+		},
+		{
+			name:     "region without sdp",
+			objects:  []runtime.Object{cloudConf("in-che")},
+			expected: map[string]bool{generalPurposeStorageClass: true, sdpStorageClass: false},
+		},
+		{
+			name: "infrastructure region wins",
+			objects: []runtime.Object{cloudConf("us-south"), &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Status: configv1.InfrastructureStatus{PlatformStatus: &configv1.PlatformStatus{
+					IBMCloud: &configv1.IBMCloudPlatformStatus{Location: "in-che"},
+				}},
+			}},
+			expected: map[string]bool{generalPurposeStorageClass: true, sdpStorageClass: false},
+		},
+		{
+			name: "regions from the operator config",
+			objects: []runtime.Object{cloudConf("in-che"), &k8v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: util.OperatorConfigMapName, Namespace: util.OperatorNamespace},
+				Data:       map[string]string{"profileRegions.sdp": "in-che, us-south", "profileRegions.general-purpose": ""},
+			}},
+			expected: map[string]bool{generalPurposeStorageClass: false, sdpStorageClass: true},
+		},
+		{
+			name:     "installed StorageClass removed",
+			objects:  []runtime.Object{cloudConf("in-che"), installedSDP},
+			expected: map[string]bool{generalPurposeStorageClass: true, sdpStorageClass: false},
+		},
+		{
+			name:     "admin StorageClass kept",
+			objects:  []runtime.Object{cloudConf("in-che"), adminSDP},
+			expected: map[string]bool{generalPurposeStorageClass: true, sdpStorageClass: true},
+		},
+		{
+			name: "unmanaged StorageClass kept",
+			objects: []runtime.Object{cloudConf("in-che"), installedSDP, &operatorv1.ClusterCSIDriver{
+				ObjectMeta: metav1.ObjectMeta{Name: "vpc.block.csi.ibm.io"},
+				Spec:       operatorv1.ClusterCSIDriverSpec{StorageClassState: operatorv1.UnmanagedStorageClass},
+			}},
+			expected: map[string]bool{generalPurposeStorageClass: false, sdpStorageClass: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestProfileStorageClassController(t, tt.objects...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			for name, expected := range tt.expected {
+				_, err := c.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					t.Fatalf("failed to get StorageClass %s: %v", name, err)
+				}
+				if exists := err == nil; exists != expected {
+					t.Errorf("StorageClass %s exists: %v, expected %v", name, exists, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncProfileStorageClassRecreated(t *testing.T) {
+	// The general-purpose StorageClass was installed with other parameters and chosen as the
+	// default StorageClass, it is re-created with the current ones and stays the default.
+	installed := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        generalPurposeStorageClass,
+			Labels:      map[string]string{appLabel: appLabelValue},
+			Annotations: map[string]string{defaultStorageClassAnnotation: "true"},
+		},
+		Provisioner: "vpc.block.csi.ibm.io",
+		Parameters:  map[string]string{"profile": "general-purpose", "encrypted": "true", "encryptionKey": "crn-old"},
+	}
+	c := newTestProfileStorageClassController(t, cloudConf("in-che"), installed)
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	sc, err := c.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), generalPurposeStorageClass, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get StorageClass %s: %v", generalPurposeStorageClass, err)
+	}
+	if sc.Parameters["encryptionKey"] != "" {
+		t.Errorf("StorageClass %s not re-created with the current parameters: %v", generalPurposeStorageClass, sc.Parameters)
+	}
+	if sc.Annotations[defaultStorageClassAnnotation] != "true" {
+		t.Errorf("StorageClass %s lost the default StorageClass annotation: %v", generalPurposeStorageClass, sc.Annotations)
+	}
+}
+
+func TestParseProfilesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "unknown field",
+			data: "profiles:\n- profile: sdp\n  file: sdp.yaml\n  region: [us-south]\n",
+		},
+		{
+			name: "missing file",
+			data: "profiles:\n- profile: sdp\n  regions: [us-south]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProfiles([]byte(tt.data)); err == nil {
+				t.Errorf("ParseProfiles() expected an error")
+			}
+		})
+	}
+}
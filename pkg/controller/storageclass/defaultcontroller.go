@@ -0,0 +1,195 @@
+package storageclass
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultStorageClassConfigKey is the key of the operator config ConfigMap with the name of
+	// the operator StorageClass that is the cluster default, or defaultStorageClassNone.
+	defaultStorageClassConfigKey = "defaultStorageClass"
+	defaultStorageClassNone      = "none"
+
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+
+	// defaultStorageClassDegradedConditionType is True when the default StorageClass chosen by
+	// the admin is not a StorageClass of the operator.
+	defaultStorageClassDegradedConditionType = "DefaultStorageClassDegraded"
+	// defaultStorageClassConflictConditionType is True when a StorageClass of the operator
+	// and a StorageClass of someone else are both marked default. It is informational and
+	// does not degrade the operator.
+	defaultStorageClassConflictConditionType = "DefaultStorageClassConflict"
+)
+
+// This DefaultStorageClassController marks the StorageClass of the operator chosen by the admin
+// as the cluster default and unmarks the other ones. The StorageClass controllers keep the
+// default annotation of an existing StorageClass as it is, so it is changed only here.
+// Without a choice in the operator config ConfigMap, the annotations are left alone and
+// the default StorageClass is the one of the assets.
+type DefaultStorageClassController struct {
+	operatorClient          v1helpers.OperatorClient
+	kubeClient              kubernetes.Interface
+	storageClassLister      storagelisters.StorageClassLister
+	operatorConfigMapLister corelisters.ConfigMapLister
+	eventRecorder           events.Recorder
+}
+
+func NewDefaultStorageClassController(
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubernetes.Interface,
+	informers v1helpers.KubeInformersForNamespaces,
+	resync time.Duration,
+	eventRecorder events.Recorder) factory.Controller {
+
+	storageClassInformer := informers.InformersFor("").Storage().V1().StorageClasses()
+	operatorConfigMapInformer := informers.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps()
+	c := &DefaultStorageClassController{
+		operatorClient:          operatorClient,
+		kubeClient:              kubeClient,
+		storageClassLister:      storageClassInformer.Lister(),
+		operatorConfigMapLister: operatorConfigMapInformer.Lister(),
+		eventRecorder:           eventRecorder.WithComponentSuffix("DefaultStorageClass"),
+	}
+	return factory.New().WithSync(c.sync).ResyncEvery(resync).WithSyncDegradedOnError(operatorClient).WithInformers(
+		operatorClient.Informer(),
+		storageClassInformer.Informer(),
+	).WithFilteredEventsInformers(
+		factory.NamesFilter(util.OperatorConfigMapName),
+		operatorConfigMapInformer.Informer(),
+	).ToController("DefaultStorageClass", eventRecorder)
+}
+
+func (c *DefaultStorageClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while getting operator state")
+		return err
+	}
+	if opSpec.ManagementState != operatorv1.Managed {
+		klog.V(2).Info("Operator management state is not managed")
+		return nil
+	}
+
+	chosen := ""
+	operatorConfig, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err == nil {
+		chosen = strings.TrimSpace(operatorConfig.Data[defaultStorageClassConfigKey])
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	storageClasses, err := c.storageClassLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	sort.Slice(storageClasses, func(i, j int) bool { return storageClasses[i].Name < storageClasses[j].Name })
+	var own []*storagev1.StorageClass
+	var othersDefault []string
+	for _, sc := range storageClasses {
+		if isOperatorStorageClass(sc) {
+			own = append(own, sc)
+		} else if isDefault(sc) {
+			othersDefault = append(othersDefault, sc.Name)
+		}
+	}
+
+	degraded := operatorv1.OperatorCondition{
+		Type:   defaultStorageClassDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if chosen != "" && chosen != defaultStorageClassNone && !containsStorageClass(own, chosen) {
+		// The StorageClass may not be created yet, the annotations are left alone meanwhile.
+		degraded.Status = operatorv1.ConditionTrue
+		degraded.Reason = "UnknownStorageClass"
+		degraded.Message = fmt.Sprintf("Key %s of configmap %s/%s names %s, which is not a StorageClass of the operator",
+			defaultStorageClassConfigKey, util.OperatorNamespace, util.OperatorConfigMapName, chosen)
+		chosen = ""
+	}
+
+	var ownDefault []string
+	for _, sc := range own {
+		if chosen != "" {
+			if err := c.setDefault(ctx, sc, sc.Name == chosen); err != nil {
+				return err
+			}
+			if sc.Name == chosen {
+				ownDefault = append(ownDefault, sc.Name)
+			}
+		} else if isDefault(sc) {
+			ownDefault = append(ownDefault, sc.Name)
+		}
+	}
+
+	conflict := operatorv1.OperatorCondition{
+		Type:   defaultStorageClassConflictConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(ownDefault) > 0 && len(othersDefault) > 0 {
+		conflict.Status = operatorv1.ConditionTrue
+		conflict.Reason = "MultipleDefaultStorageClasses"
+		conflict.Message = fmt.Sprintf("StorageClasses %s are marked default besides %s of the operator. Set %s to %s in configmap %s/%s or unmark them",
+			strings.Join(othersDefault, ", "), strings.Join(ownDefault, ", "), defaultStorageClassConfigKey, defaultStorageClassNone, util.OperatorNamespace, util.OperatorConfigMapName)
+		klog.V(2).Infof("%s", conflict.Message)
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(degraded), v1helpers.UpdateConditionFn(conflict))
+	return err
+}
+
+// setDefault sets the default annotation of sc to isDefaultClass.
+func (c *DefaultStorageClassController) setDefault(ctx context.Context, sc *storagev1.StorageClass, isDefaultClass bool) error {
+	if isDefault(sc) == isDefaultClass && sc.Annotations[betaDefaultStorageClassAnnotation] == "" {
+		return nil
+	}
+	updated := sc.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[defaultStorageClassAnnotation] = fmt.Sprintf("%t", isDefaultClass)
+	delete(updated.Annotations, betaDefaultStorageClassAnnotation)
+	if _, err := c.kubeClient.StorageV1().StorageClasses().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the default annotation of StorageClass %s: %w", sc.Name, err)
+	}
+	if isDefaultClass {
+		c.eventRecorder.Eventf("DefaultStorageClassChanged", "StorageClass %s is the default StorageClass", sc.Name)
+	}
+	return nil
+}
+
+// isOperatorStorageClass returns true for the built-in, profile and template StorageClasses.
+func isOperatorStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Provisioner == provisioner && (sc.Labels[appLabel] == appLabelValue || sc.Labels[templateLabel] == "true")
+}
+
+func isDefault(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[defaultStorageClassAnnotation] == "true" || sc.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
+
+func containsStorageClass(storageClasses []*storagev1.StorageClass, name string) bool {
+	for _, sc := range storageClasses {
+		if sc.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package storageclass
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func newTestDefaultStorageClassController(objects ...runtime.Object) *DefaultStorageClassController {
+	storageClassIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *storagev1.StorageClass:
+			storageClassIndexer.Add(o)
+		case *k8v1.ConfigMap:
+			configMapIndexer.Add(o)
+		}
+	}
+	return &DefaultStorageClassController{
+		operatorClient: v1helpers.NewFakeOperatorClient(
+			&operatorv1.OperatorSpec{ManagementState: operatorv1.Managed},
+			&operatorv1.OperatorStatus{},
+			nil,
+		),
+		kubeClient:              fake.NewSimpleClientset(objects...),
+		storageClassLister:      storagelisters.NewStorageClassLister(storageClassIndexer),
+		operatorConfigMapLister: corelisters.NewConfigMapLister(configMapIndexer),
+		eventRecorder:           events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now())),
+	}
+}
+
+func storageClass(name string, labels map[string]string, isDefaultClass string) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Labels: labels},
+		Provisioner: provisioner,
+	}
+	if isDefaultClass != "" {
+		sc.Annotations = map[string]string{defaultStorageClassAnnotation: isDefaultClass}
+	}
+	return sc
+}
+
+func defaultStorageClassConfig(value string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: util.OperatorConfigMapName, Namespace: util.OperatorNamespace},
+		Data:       map[string]string{defaultStorageClassConfigKey: value},
+	}
+}
+
+func TestSyncDefaultStorageClass(t *testing.T) {
+	builtin := map[string]string{appLabel: appLabelValue}
+	template := map[string]string{templateLabel: "true"}
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		expected         map[string]string
+		expectedDegraded operatorv1.ConditionStatus
+		expectedConflict operatorv1.ConditionStatus
+	}{
+		{
+			name: "no choice keeps the annotations",
+			objects: []runtime.Object{
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("ibmc-vpc-block-5iops-tier", builtin, ""),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "true", "ibmc-vpc-block-5iops-tier": ""},
+			expectedDegraded: operatorv1.ConditionFalse,
+			expectedConflict: operatorv1.ConditionFalse,
+		},
+		{
+			name: "chosen built-in class",
+			objects: []runtime.Object{
+				defaultStorageClassConfig("ibmc-vpc-block-5iops-tier"),
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("ibmc-vpc-block-5iops-tier", builtin, ""),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "false", "ibmc-vpc-block-5iops-tier": "true"},
+			expectedDegraded: operatorv1.ConditionFalse,
+			expectedConflict: operatorv1.ConditionFalse,
+		},
+		{
+			name: "chosen template class",
+			objects: []runtime.Object{
+				defaultStorageClassConfig("ibmc-vpc-block-retain-xfs"),
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("ibmc-vpc-block-retain-xfs", template, "false"),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "false", "ibmc-vpc-block-retain-xfs": "true"},
+			expectedDegraded: operatorv1.ConditionFalse,
+			expectedConflict: operatorv1.ConditionFalse,
+		},
+		{
+			name: "none",
+			objects: []runtime.Object{
+				defaultStorageClassConfig(defaultStorageClassNone),
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("other", nil, "true"),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "false", "other": "true"},
+			expectedDegraded: operatorv1.ConditionFalse,
+			expectedConflict: operatorv1.ConditionFalse,
+		},
+		{
+			name: "unknown class",
+			objects: []runtime.Object{
+				defaultStorageClassConfig("other"),
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("other", nil, ""),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "true", "other": ""},
+			expectedDegraded: operatorv1.ConditionTrue,
+			expectedConflict: operatorv1.ConditionFalse,
+		},
+		{
+			name: "conflict",
+			objects: []runtime.Object{
+				storageClass("ibmc-vpc-block-10iops-tier", builtin, "true"),
+				storageClass("other", nil, "true"),
+			},
+			expected:         map[string]string{"ibmc-vpc-block-10iops-tier": "true", "other": "true"},
+			expectedDegraded: operatorv1.ConditionFalse,
+			expectedConflict: operatorv1.ConditionTrue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestDefaultStorageClassController(tt.objects...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			for name, expected := range tt.expected {
+				sc, err := c.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get StorageClass %s: %v", name, err)
+				}
+				if got := sc.Annotations[defaultStorageClassAnnotation]; got != expected {
+					t.Errorf("StorageClass %s default annotation %q, expected %q", name, got, expected)
+				}
+			}
+
+			_, status, _, err := c.operatorClient.GetOperatorState()
+			if err != nil {
+				t.Fatalf("failed to get operator state: %v", err)
+			}
+			degraded := v1helpers.FindOperatorCondition(status.Conditions, defaultStorageClassDegradedConditionType)
+			if degraded == nil || degraded.Status != tt.expectedDegraded {
+				t.Errorf("expected %s=%s, got %+v", defaultStorageClassDegradedConditionType, tt.expectedDegraded, degraded)
+			}
+			conflict := v1helpers.FindOperatorCondition(status.Conditions, defaultStorageClassConflictConditionType)
+			if conflict == nil || conflict.Status != tt.expectedConflict {
+				t.Errorf("expected %s=%s, got %+v", defaultStorageClassConflictConditionType, tt.expectedConflict, conflict)
+			}
+		})
+	}
+}
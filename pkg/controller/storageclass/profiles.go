@@ -0,0 +1,72 @@
+package storageclass
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// allRegions in the regions of a profile makes it available everywhere.
+	allRegions = "*"
+	// profileRegionsKeyPrefix prefixes the keys of the operator config ConfigMap that replace
+	// the regions of a profile, e.g. profileRegions.sdp.
+	profileRegionsKeyPrefix = "profileRegions."
+)
+
+// Profile is a block volume profile with a StorageClass installed only in the regions
+// where IBM Cloud offers the profile.
+type Profile struct {
+	// Profile is the name of the VPC block volume profile.
+	Profile string `json:"profile"`
+	// File is the asset with the StorageClass of the profile.
+	File string `json:"file"`
+	// Regions are the regions where the profile is available.
+	Regions []string `json:"regions"`
+}
+
+type profileList struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// ParseProfiles parses the profiles asset.
+func ParseProfiles(data []byte) ([]Profile, error) {
+	var list profileList
+	if err := yaml.UnmarshalStrict(data, &list); err != nil {
+		return nil, err
+	}
+	for _, p := range list.Profiles {
+		if p.Profile == "" || p.File == "" {
+			return nil, fmt.Errorf("profile %q must have a name and a StorageClass file", p.Profile)
+		}
+	}
+	return list.Profiles, nil
+}
+
+// regions returns the regions of p, replaced by the admin in operatorConfig, which may be nil.
+func (p *Profile) regions(operatorConfig *v1.ConfigMap) []string {
+	if operatorConfig != nil {
+		if value, ok := operatorConfig.Data[profileRegionsKeyPrefix+p.Profile]; ok {
+			var regions []string
+			for _, region := range strings.Split(value, ",") {
+				if region = strings.TrimSpace(region); region != "" {
+					regions = append(regions, region)
+				}
+			}
+			return regions
+		}
+	}
+	return p.Regions
+}
+
+// availableIn returns true when p is offered in region.
+func (p *Profile) availableIn(region string, operatorConfig *v1.ConfigMap) bool {
+	for _, r := range p.regions(operatorConfig) {
+		if r == allRegions || r == region {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package storageclass
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	operatorinformers "github.com/openshift/client-go/operator/informers/externalversions"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// templatesDegradedConditionType is True when a StorageClass template is invalid.
+	templatesDegradedConditionType = "StorageClassTemplatesDegraded"
+)
+
+// This TemplateStorageClassController applies the StorageClass templates of the admin from
+// the StorageClass templates ConfigMap, with the same hooks as the built-in StorageClasses,
+// and deletes the StorageClasses whose template was removed.
+type TemplateStorageClassController struct {
+	operatorClient          v1helpers.OperatorClient
+	kubeClient              kubernetes.Interface
+	storageClassLister      storagelisters.StorageClassLister
+	operatorConfigMapLister corelisters.ConfigMapLister
+	eventRecorder           events.Recorder
+	applier                 *storageClassApplier
+	// reserved are the names of the StorageClasses shipped with the operator.
+	reserved map[string]bool
+}
+
+func NewTemplateStorageClassController(
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubernetes.Interface,
+	informers v1helpers.KubeInformersForNamespaces,
+	operatorInformers operatorinformers.SharedInformerFactory,
+	reserved []string,
+	resync time.Duration,
+	eventRecorder events.Recorder,
+	hooks ...csistorageclasscontroller.StorageClassHookFunc) factory.Controller {
+
+	storageClassInformer := informers.InformersFor("").Storage().V1().StorageClasses()
+	operatorConfigMapInformer := informers.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps()
+	c := &TemplateStorageClassController{
+		operatorClient:          operatorClient,
+		kubeClient:              kubeClient,
+		storageClassLister:      storageClassInformer.Lister(),
+		operatorConfigMapLister: operatorConfigMapInformer.Lister(),
+		eventRecorder:           eventRecorder.WithComponentSuffix("TemplateStorageClass"),
+		applier:                 newStorageClassApplier(kubeClient, storageClassInformer.Lister(), operatorInformers.Operator().V1().ClusterCSIDrivers().Lister(), eventRecorder, hooks),
+		reserved:                map[string]bool{},
+	}
+	for _, name := range reserved {
+		c.reserved[name] = true
+	}
+	return factory.New().WithSync(c.sync).ResyncEvery(resync).WithSyncDegradedOnError(operatorClient).WithInformers(
+		operatorClient.Informer(),
+		storageClassInformer.Informer(),
+		operatorInformers.Operator().V1().ClusterCSIDrivers().Informer(),
+	).WithFilteredEventsInformers(
+		factory.NamesFilter(util.StorageClassTemplatesConfigMapName),
+		operatorConfigMapInformer.Informer(),
+	).ToController("TemplateStorageClass", eventRecorder)
+}
+
+func (c *TemplateStorageClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while getting operator state")
+		return err
+	}
+	if opSpec.ManagementState != operatorv1.Managed {
+		klog.V(2).Info("Operator management state is not managed")
+		return nil
+	}
+
+	templates := map[string]string{}
+	templatesConfigMap, err := c.operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.StorageClassTemplatesConfigMapName)
+	if err == nil {
+		templates = templatesConfigMap.Data
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var storageClasses []*storagev1.StorageClass
+	var invalid []string
+	names := map[string]string{}
+	for _, key := range keys {
+		sc, err := parseTemplate(templates[key], c.reserved)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if other, ok := names[sc.Name]; ok {
+			invalid = append(invalid, fmt.Sprintf("%s: StorageClass %s is already defined by %s", key, sc.Name, other))
+			continue
+		}
+		names[sc.Name] = key
+		storageClasses = append(storageClasses, sc)
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type:   templatesDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(invalid) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "InvalidTemplates"
+		condition.Message = fmt.Sprintf("Invalid StorageClass templates in configmap %s/%s: %s",
+			util.OperatorNamespace, util.StorageClassTemplatesConfigMapName, strings.Join(invalid, "; "))
+		klog.V(2).Infof("%s", condition.Message)
+	}
+	if _, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(condition)); err != nil {
+		return err
+	}
+
+	for _, sc := range storageClasses {
+		if err := c.applier.apply(ctx, opSpec, sc); err != nil {
+			return fmt.Errorf("failed to apply StorageClass %s from template %s: %w", sc.Name, names[sc.Name], err)
+		}
+	}
+
+	// The StorageClass of an invalid template may still be in use, nothing is removed
+	// until all templates are valid.
+	if len(invalid) > 0 {
+		return nil
+	}
+	return c.removeOrphans(ctx, names)
+}
+
+// removeOrphans deletes the StorageClasses created from a template that is not in names anymore.
+func (c *TemplateStorageClassController) removeOrphans(ctx context.Context, names map[string]string) error {
+	if !c.applier.managed(provisioner) {
+		return nil
+	}
+	existing, err := c.storageClassLister.List(labels.SelectorFromSet(labels.Set{templateLabel: "true"}))
+	if err != nil {
+		return err
+	}
+	for _, sc := range existing {
+		if _, ok := names[sc.Name]; ok || sc.Provisioner != provisioner {
+			continue
+		}
+		if _, _, err := resourceapply.DeleteStorageClass(ctx, c.kubeClient.StorageV1(), c.eventRecorder, sc); err != nil {
+			return fmt.Errorf("failed to remove StorageClass %s: %w", sc.Name, err)
+		}
+		c.eventRecorder.Eventf("StorageClassTemplateRemoved", "Removed StorageClass %s, its template was removed from configmap %s", sc.Name, util.StorageClassTemplatesConfigMapName)
+	}
+	return nil
+}
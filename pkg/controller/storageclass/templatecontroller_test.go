@@ -0,0 +1,256 @@
+package storageclass
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	operatorlisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+const retainTemplate = `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: ibmc-vpc-block-retain-xfs
+parameters:
+  csi.storage.k8s.io/fstype: xfs
+  profile: custom
+  iops: "1000"
+reclaimPolicy: Retain
+volumeBindingMode: WaitForFirstConsumer
+`
+
+func newTestTemplateStorageClassController(objects ...runtime.Object) *TemplateStorageClassController {
+	storageClassIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	clusterCSIDriverIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var kubeObjects []runtime.Object
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *storagev1.StorageClass:
+			storageClassIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *k8v1.ConfigMap:
+			configMapIndexer.Add(o)
+			kubeObjects = append(kubeObjects, o)
+		case *operatorv1.ClusterCSIDriver:
+			clusterCSIDriverIndexer.Add(o)
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(kubeObjects...)
+	eventRecorder := events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now()))
+	// The hook stands in for the encryption key hook of the operator.
+	hook := func(_ *operatorv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		sc.Parameters["encryptionKey"] = "crn:v1:test"
+		return nil
+	}
+	return &TemplateStorageClassController{
+		operatorClient: v1helpers.NewFakeOperatorClient(
+			&operatorv1.OperatorSpec{ManagementState: operatorv1.Managed},
+			&operatorv1.OperatorStatus{},
+			nil,
+		),
+		kubeClient:              kubeClient,
+		storageClassLister:      storagelisters.NewStorageClassLister(storageClassIndexer),
+		operatorConfigMapLister: corelisters.NewConfigMapLister(configMapIndexer),
+		eventRecorder:           eventRecorder,
+		applier: newStorageClassApplier(
+			kubeClient,
+			storagelisters.NewStorageClassLister(storageClassIndexer),
+			operatorlisters.NewClusterCSIDriverLister(clusterCSIDriverIndexer),
+			eventRecorder,
+			[]csistorageclasscontroller.StorageClassHookFunc{hook},
+		),
+		reserved: map[string]bool{"ibmc-vpc-block-10iops-tier": true},
+	}
+}
+
+func templatesConfigMap(templates map[string]string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.StorageClassTemplatesConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: templates,
+	}
+}
+
+func templateStorageClass(name string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{templateLabel: "true"},
+		},
+		Provisioner: provisioner,
+	}
+}
+
+func TestSyncStorageClassTemplates(t *testing.T) {
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		expected         map[string]bool
+		expectedDegraded operatorv1.ConditionStatus
+		expectedMessage  string
+	}{
+		{
+			name:             "no templates",
+			objects:          []runtime.Object{templateStorageClass("ibmc-vpc-block-old")},
+			expected:         map[string]bool{"ibmc-vpc-block-old": false},
+			expectedDegraded: operatorv1.ConditionFalse,
+		},
+		{
+			name: "template applied and removed template collected",
+			objects: []runtime.Object{
+				templatesConfigMap(map[string]string{"retain-xfs.yaml": retainTemplate}),
+				templateStorageClass("ibmc-vpc-block-old"),
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "admin-created"}, Provisioner: provisioner},
+			},
+			expected:         map[string]bool{"ibmc-vpc-block-retain-xfs": true, "ibmc-vpc-block-old": false, "admin-created": true},
+			expectedDegraded: operatorv1.ConditionFalse,
+		},
+		{
+			name: "invalid template pauses the garbage collection",
+			objects: []runtime.Object{
+				templatesConfigMap(map[string]string{
+					"retain-xfs.yaml": retainTemplate,
+					"broken.yaml":     strings.Replace(retainTemplate, `iops: "1000"`, `iops: "90000"`, 1),
+				}),
+				templateStorageClass("ibmc-vpc-block-old"),
+			},
+			expected:         map[string]bool{"ibmc-vpc-block-retain-xfs": true, "ibmc-vpc-block-old": true},
+			expectedDegraded: operatorv1.ConditionTrue,
+			expectedMessage:  "broken.yaml",
+		},
+		{
+			name: "unmanaged StorageClasses are not collected",
+			objects: []runtime.Object{
+				templateStorageClass("ibmc-vpc-block-old"),
+				&operatorv1.ClusterCSIDriver{
+					ObjectMeta: metav1.ObjectMeta{Name: provisioner},
+					Spec:       operatorv1.ClusterCSIDriverSpec{StorageClassState: operatorv1.UnmanagedStorageClass},
+				},
+			},
+			expected:         map[string]bool{"ibmc-vpc-block-old": true},
+			expectedDegraded: operatorv1.ConditionFalse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTemplateStorageClassController(tt.objects...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			for name, expected := range tt.expected {
+				_, err := c.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					t.Fatalf("failed to get StorageClass %s: %v", name, err)
+				}
+				if exists := err == nil; exists != expected {
+					t.Errorf("StorageClass %s exists: %v, expected %v", name, exists, expected)
+				}
+			}
+
+			_, status, _, err := c.operatorClient.GetOperatorState()
+			if err != nil {
+				t.Fatalf("failed to get operator state: %v", err)
+			}
+			condition := v1helpers.FindOperatorCondition(status.Conditions, templatesDegradedConditionType)
+			if condition == nil || condition.Status != tt.expectedDegraded || !strings.Contains(condition.Message, tt.expectedMessage) {
+				t.Errorf("expected %s=%s with message containing %q, got %+v", templatesDegradedConditionType, tt.expectedDegraded, tt.expectedMessage, condition)
+			}
+		})
+	}
+}
+
+func TestSyncStorageClassTemplateHooks(t *testing.T) {
+	c := newTestTemplateStorageClassController(templatesConfigMap(map[string]string{"retain-xfs.yaml": retainTemplate}))
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+		t.Fatalf("sync() unexpected error: %v", err)
+	}
+	sc, err := c.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), "ibmc-vpc-block-retain-xfs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the StorageClass: %v", err)
+	}
+	if sc.Provisioner != provisioner || sc.Labels[templateLabel] != "true" || sc.Parameters["encryptionKey"] != "crn:v1:test" {
+		t.Errorf("unexpected StorageClass %+v", sc)
+	}
+	if sc.ReclaimPolicy == nil || *sc.ReclaimPolicy != k8v1.PersistentVolumeReclaimRetain {
+		t.Errorf("reclaim policy not taken from the template: %v", sc.ReclaimPolicy)
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "not a StorageClass",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n",
+			expected: "no kind",
+		},
+		{
+			name:     "reserved name",
+			template: strings.Replace(retainTemplate, "ibmc-vpc-block-retain-xfs", "ibmc-vpc-block-10iops-tier", 1),
+			expected: "managed by the operator",
+		},
+		{
+			name:     "other provisioner",
+			template: strings.Replace(retainTemplate, "reclaimPolicy", "provisioner: ebs.csi.aws.com\nreclaimPolicy", 1),
+			expected: "provisioner",
+		},
+		{
+			name:     "unknown profile",
+			template: strings.Replace(retainTemplate, "profile: custom", "profile: gold", 1),
+			expected: "profile",
+		},
+		{
+			name:     "iops out of range",
+			template: strings.Replace(retainTemplate, `iops: "1000"`, `iops: "50"`, 1),
+			expected: "between 100 and 48000",
+		},
+		{
+			name:     "iops with a tiered profile",
+			template: strings.Replace(retainTemplate, "profile: custom", "profile: 10iops-tier", 1),
+			expected: "not supported by profile 10iops-tier",
+		},
+		{
+			name:     "unknown fstype",
+			template: strings.Replace(retainTemplate, "fstype: xfs", "fstype: btrfs", 1),
+			expected: "fstype",
+		},
+		{
+			name:     "unknown field",
+			template: retainTemplate + "reclaim: Retain\n",
+			expected: "unknown field",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTemplate(tt.template, map[string]bool{"ibmc-vpc-block-10iops-tier": true})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("parseTemplate() got error %v, expected one containing %q", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package storageclass
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// provisioner is the name of the CSI driver.
+	provisioner = "vpc.block.csi.ibm.io"
+	// templateLabel marks the StorageClasses created from a template of the admin, so the
+	// ones whose template is removed can be garbage collected.
+	templateLabel = "vpc.block.csi.ibm.io/storageclass-template"
+
+	profileParameter    = "profile"
+	iopsParameter       = "iops"
+	throughputParameter = "throughput"
+	fsTypeParameter     = "csi.storage.k8s.io/fstype"
+)
+
+var (
+	storageScheme = runtime.NewScheme()
+	storageCodecs = serializer.NewCodecFactory(storageScheme, serializer.EnableStrict)
+)
+
+func init() {
+	utilruntime.Must(storagev1.AddToScheme(storageScheme))
+}
+
+// valueRange is an inclusive range of a numeric StorageClass parameter.
+type valueRange struct {
+	min, max int
+}
+
+// profileLimits are the parameters a block volume profile accepts besides the common ones.
+// A profile without iops or throughput range does not accept the parameter.
+type profileLimits struct {
+	iops       *valueRange
+	throughput *valueRange
+}
+
+var templateProfiles = map[string]profileLimits{
+	"5iops-tier":      {},
+	"10iops-tier":     {},
+	"general-purpose": {},
+	"custom":          {iops: &valueRange{100, 48000}},
+	// The throughput of sdp volumes is in Mbps.
+	"sdp": {iops: &valueRange{3000, 64000}, throughput: &valueRange{125, 8192}},
+}
+
+var templateFSTypes = map[string]bool{"ext3": true, "ext4": true, "xfs": true}
+
+// parseTemplate decodes and validates the StorageClass template in data. reserved are the
+// names of the StorageClasses shipped with the operator, which templates cannot replace.
+func parseTemplate(data string, reserved map[string]bool) (*storagev1.StorageClass, error) {
+	obj, err := runtime.Decode(storageCodecs.UniversalDecoder(storagev1.SchemeGroupVersion), []byte(data))
+	if err != nil {
+		return nil, err
+	}
+	sc, ok := obj.(*storagev1.StorageClass)
+	if !ok {
+		return nil, fmt.Errorf("expected a StorageClass, got %T", obj)
+	}
+	if errs := validation.IsDNS1123Subdomain(sc.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid name %q: %v", sc.Name, errs)
+	}
+	if reserved[sc.Name] {
+		return nil, fmt.Errorf("StorageClass %s is managed by the operator", sc.Name)
+	}
+	if sc.Provisioner == "" {
+		sc.Provisioner = provisioner
+	}
+	if sc.Provisioner != provisioner {
+		return nil, fmt.Errorf("provisioner must be %s, got %s", provisioner, sc.Provisioner)
+	}
+
+	profile := sc.Parameters[profileParameter]
+	limits, ok := templateProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("parameter %s must be one of %v, got %q", profileParameter, sortedKeys(templateProfiles), profile)
+	}
+	if err := validateRange(sc.Parameters, iopsParameter, profile, limits.iops); err != nil {
+		return nil, err
+	}
+	if err := validateRange(sc.Parameters, throughputParameter, profile, limits.throughput); err != nil {
+		return nil, err
+	}
+	if fsType, ok := sc.Parameters[fsTypeParameter]; ok && !templateFSTypes[fsType] {
+		return nil, fmt.Errorf("parameter %s must be one of ext3, ext4, xfs, got %q", fsTypeParameter, fsType)
+	}
+
+	if sc.Labels == nil {
+		sc.Labels = map[string]string{}
+	}
+	sc.Labels[templateLabel] = "true"
+	return sc, nil
+}
+
+// validateRange checks that parameter key of a StorageClass with the given profile is an
+// integer within limits, or that it is not set when the profile has no limits.
+func validateRange(parameters map[string]string, key, profile string, limits *valueRange) error {
+	value, ok := parameters[key]
+	if !ok || value == "" {
+		return nil
+	}
+	if limits == nil {
+		return fmt.Errorf("parameter %s is not supported by profile %s", key, profile)
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("parameter %s must be an integer, got %q", key, value)
+	}
+	if i < limits.min || i > limits.max {
+		return fmt.Errorf("parameter %s of profile %s must be between %d and %d, got %d", key, profile, limits.min, limits.max, i)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]profileLimits) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+package tunables
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// tunablesDegradedConditionType is True when the operator config ConfigMap contains
+	// invalid driver tunables.
+	tunablesDegradedConditionType = "DriverTunablesDegraded"
+)
+
+// This TunablesController renders the driver ConfigMap from its manifest and the driver
+// tunables set by the admin in the operator config ConfigMap. The controller Deployment and
+// node DaemonSet carry the hash of the rendered ConfigMap and restart when it changes.
+type TunablesController struct {
+	operatorClient  v1helpers.OperatorClient
+	kubeClient      kubernetes.Interface
+	configMapLister corelisters.ConfigMapLister
+	eventRecorder   events.Recorder
+	// manifest is the driver ConfigMap with the default tunables.
+	manifest *v1.ConfigMap
+}
+
+func NewTunablesController(
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubernetes.Interface,
+	informers v1helpers.KubeInformersForNamespaces,
+	manifest []byte,
+	resync time.Duration,
+	eventRecorder events.Recorder) factory.Controller {
+
+	configMapInformer := informers.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps()
+	c := &TunablesController{
+		operatorClient:  operatorClient,
+		kubeClient:      kubeClient,
+		configMapLister: configMapInformer.Lister(),
+		eventRecorder:   eventRecorder.WithComponentSuffix("DriverTunables"),
+		manifest:        resourceread.ReadConfigMapV1OrDie(manifest),
+	}
+	return factory.New().WithSync(c.sync).ResyncEvery(resync).WithSyncDegradedOnError(operatorClient).WithInformers(
+		operatorClient.Informer(),
+	).WithFilteredEventsInformers(
+		factory.NamesFilter(util.OperatorConfigMapName, util.DriverConfigMapName),
+		configMapInformer.Informer(),
+	).ToController("DriverTunables", eventRecorder)
+}
+
+func (c *TunablesController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while getting operator state")
+		return err
+	}
+	if opSpec.ManagementState != operatorv1.Managed {
+		klog.V(2).Info("Operator management state is not managed")
+		return nil
+	}
+
+	operatorConfig, err := c.configMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		operatorConfig = nil
+	}
+	overrides, invalid := parseTunables(operatorConfig)
+
+	condition := operatorv1.OperatorCondition{
+		Type:   tunablesDegradedConditionType,
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(invalid) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "InvalidTunables"
+		condition.Message = fmt.Sprintf("Invalid driver tunables in configmap %s/%s, keeping the current %s: %s",
+			util.OperatorNamespace, util.OperatorConfigMapName, util.DriverConfigMapName, strings.Join(invalid, "; "))
+		klog.V(2).Infof("%s", condition.Message)
+	}
+	if _, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(condition)); err != nil {
+		return err
+	}
+
+	// Invalid tunables must not restart the driver, the current ConfigMap stays in place
+	// until they are fixed. The defaults are used only when there is nothing to keep.
+	if len(invalid) > 0 {
+		_, err := c.configMapLister.ConfigMaps(util.OperatorNamespace).Get(util.DriverConfigMapName)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		overrides = nil
+	}
+
+	required := c.manifest.DeepCopy()
+	for key, value := range overrides {
+		required.Data[key] = value
+	}
+	_, modified, err := resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), c.eventRecorder, required)
+	if err != nil {
+		klog.V(2).ErrorS(err, "Error while applying the driver configmap")
+		return err
+	}
+	if modified && len(overrides) > 0 {
+		c.eventRecorder.Eventf("DriverTunablesApplied", "%s updated with tunables %s", util.DriverConfigMapName, formatTunables(overrides))
+	}
+	return nil
+}
+
+// formatTunables returns the tunables as a sorted list of key=value.
+func formatTunables(overrides map[string]string) string {
+	var pairs []string
+	for key, value := range overrides {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,146 @@
+package tunables
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/assets"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func newTestTunablesController(t *testing.T, objects ...*k8v1.ConfigMap) *TunablesController {
+	manifest, err := assets.ReadFile("configmap.yaml")
+	if err != nil {
+		t.Fatalf("failed to read the driver configmap asset: %v", err)
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	var kubeObjects []runtime.Object
+	for _, obj := range objects {
+		indexer.Add(obj)
+		kubeObjects = append(kubeObjects, obj)
+	}
+	return &TunablesController{
+		operatorClient: v1helpers.NewFakeOperatorClient(
+			&operatorv1.OperatorSpec{ManagementState: operatorv1.Managed},
+			&operatorv1.OperatorStatus{},
+			nil,
+		),
+		kubeClient:      fake.NewSimpleClientset(kubeObjects...),
+		configMapLister: corelisters.NewConfigMapLister(indexer),
+		eventRecorder:   events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now())),
+		manifest:        resourceread.ReadConfigMapV1OrDie(manifest),
+	}
+}
+
+func operatorConfig(data map[string]string) *k8v1.ConfigMap {
+	return &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.OperatorConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: data,
+	}
+}
+
+func TestSyncTunables(t *testing.T) {
+	existing := &k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.DriverConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string]string{"VPC_RETRY_ATTEMPT": "20"},
+	}
+	tests := []struct {
+		name             string
+		objects          []*k8v1.ConfigMap
+		expectedData     map[string]string
+		expectedDegraded operatorv1.ConditionStatus
+		expectedMessage  []string
+	}{
+		{
+			name:             "defaults",
+			expectedData:     map[string]string{"VPC_RETRY_ATTEMPT": "10", "VPC_RETRY_INTERVAL": "120", "VPC_API_TIMEOUT": "180s", "VPC_API_VERSION": "2019-07-02", "VPC_API_GENERATION": "1"},
+			expectedDegraded: operatorv1.ConditionFalse,
+		},
+		{
+			name: "valid overrides",
+			objects: []*k8v1.ConfigMap{operatorConfig(map[string]string{
+				"VPC_RETRY_ATTEMPT":  "20",
+				"VPC_RETRY_INTERVAL": " 300 ",
+				"VPC_API_TIMEOUT":    "10m",
+				"VPC_API_VERSION":    "2024-04-30",
+				"VPC_API_GENERATION": "2",
+				"resourceGroupID":    "not a tunable",
+			})},
+			expectedData:     map[string]string{"VPC_RETRY_ATTEMPT": "20", "VPC_RETRY_INTERVAL": "300", "VPC_API_TIMEOUT": "10m", "VPC_API_VERSION": "2024-04-30", "VPC_API_GENERATION": "2", "CSI_ENDPOINT": "unix:/csi/csi.sock"},
+			expectedDegraded: operatorv1.ConditionFalse,
+		},
+		{
+			name: "invalid overrides keep the current configmap",
+			objects: []*k8v1.ConfigMap{existing, operatorConfig(map[string]string{
+				"VPC_RETRY_ATTEMPT":  "100",
+				"VPC_RETRY_INTERVAL": "2m",
+				"VPC_API_TIMEOUT":    "1s",
+				"VPC_API_VERSION":    "2019-01-01",
+				"VPC_API_GENERATION": "3",
+			})},
+			expectedData:     map[string]string{"VPC_RETRY_ATTEMPT": "20"},
+			expectedDegraded: operatorv1.ConditionTrue,
+			expectedMessage:  []string{"VPC_RETRY_ATTEMPT", "VPC_RETRY_INTERVAL", "VPC_API_TIMEOUT", "VPC_API_VERSION", "VPC_API_GENERATION"},
+		},
+		{
+			name:             "invalid overrides without configmap use the defaults",
+			objects:          []*k8v1.ConfigMap{operatorConfig(map[string]string{"VPC_RETRY_ATTEMPT": "20", "VPC_API_TIMEOUT": "forever"})},
+			expectedData:     map[string]string{"VPC_RETRY_ATTEMPT": "10", "VPC_API_TIMEOUT": "180s"},
+			expectedDegraded: operatorv1.ConditionTrue,
+			expectedMessage:  []string{"VPC_API_TIMEOUT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTunablesController(t, tt.objects...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+
+			driverConfigMap, err := c.kubeClient.CoreV1().ConfigMaps(util.OperatorNamespace).Get(context.TODO(), util.DriverConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", util.DriverConfigMapName, err)
+			}
+			for key, value := range tt.expectedData {
+				if driverConfigMap.Data[key] != value {
+					t.Errorf("%s got %q, expected %q", key, driverConfigMap.Data[key], value)
+				}
+			}
+
+			_, status, _, err := c.operatorClient.GetOperatorState()
+			if err != nil {
+				t.Fatalf("failed to get operator state: %v", err)
+			}
+			condition := v1helpers.FindOperatorCondition(status.Conditions, tunablesDegradedConditionType)
+			if condition == nil || condition.Status != tt.expectedDegraded {
+				t.Fatalf("expected %s=%s, got %+v", tunablesDegradedConditionType, tt.expectedDegraded, condition)
+			}
+			for _, expected := range tt.expectedMessage {
+				if !strings.Contains(condition.Message, expected) {
+					t.Errorf("condition message %q does not contain %s", condition.Message, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package tunables
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// tunable is a key of the driver ConfigMap that the admin may override in the operator
+// config ConfigMap, under the same name.
+type tunable struct {
+	key      string
+	validate func(value string) error
+}
+
+// minAPIVersion is the VPC API version shipped in assets/configmap.yaml, older versions lack
+// features the driver relies on.
+var minAPIVersion = time.Date(2019, 7, 2, 0, 0, 0, 0, time.UTC)
+
+var tunables = []tunable{
+	{key: "VPC_RETRY_ATTEMPT", validate: intInRange(1, 50)},
+	{key: "VPC_RETRY_INTERVAL", validate: intInRange(1, 600)},
+	{key: "VPC_API_TIMEOUT", validate: durationInRange(10*time.Second, 30*time.Minute)},
+	{key: "VPC_API_VERSION", validate: apiVersion},
+	{key: "VPC_API_GENERATION", validate: oneOf("1", "2")},
+}
+
+// parseTunables returns the valid tunables set in operatorConfig and a description of each
+// invalid one. operatorConfig may be nil.
+func parseTunables(operatorConfig *v1.ConfigMap) (map[string]string, []string) {
+	overrides := map[string]string{}
+	var invalid []string
+	if operatorConfig == nil {
+		return overrides, nil
+	}
+	for _, t := range tunables {
+		value, ok := operatorConfig.Data[t.key]
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if err := t.validate(value); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s=%q: %v", t.key, value, err))
+			continue
+		}
+		overrides[t.key] = value
+	}
+	sort.Strings(invalid)
+	return overrides, invalid
+}
+
+func intInRange(min, max int) func(string) error {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		if i < min || i > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+func durationInRange(min, max time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration, e.g. 180s")
+		}
+		if d < min || d > max {
+			return fmt.Errorf("must be between %s and %s", min, max)
+		}
+		return nil
+	}
+}
+
+func apiVersion(value string) error {
+	version, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return fmt.Errorf("must be a date in the YYYY-MM-DD format")
+	}
+	if version.Before(minAPIVersion) {
+		return fmt.Errorf("must not be older than %s", minAPIVersion.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
@@ -0,0 +1,159 @@
+package ibmcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// authzBulkPath is the path of the bulk authorization API of IAM, relative to the IAM endpoint.
+	authzBulkPath = "/v2/authz/bulk"
+	// vpcServiceName is the IAM service name of VPC Infrastructure Services.
+	vpcServiceName = "is"
+)
+
+// authzRequest is a single access check of the IAM authorization API.
+type authzRequest struct {
+	Subject  authzAttributes `json:"subject"`
+	Action   string          `json:"action"`
+	Resource authzAttributes `json:"resource"`
+}
+
+type authzAttributes struct {
+	Attributes map[string]string `json:"attributes"`
+}
+
+type authzBulkRequest struct {
+	Requests []authzRequest `json:"requests"`
+}
+
+type authzBulkResponse struct {
+	Responses []struct {
+		AuthorizationDecision *struct {
+			Permitted bool `json:"permitted"`
+		} `json:"authorizationDecision"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"responses"`
+}
+
+// MissingPermissions asks IAM at iamEndpoint which of actions of the VPC Infrastructure
+// service the identity owning apiKey is not allowed to perform on resources of resource
+// group resourceGroupID in account accountID. When accountID is empty, the account the
+// identity belongs to is used. Failed calls are reported as *APIError.
+func MissingPermissions(ctx context.Context, client *http.Client, apiKey, iamEndpoint, accountID, resourceGroupID string, actions []string) ([]string, error) {
+	accessToken, err := RequestIAMToken(ctx, client, apiKey, iamEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := parseTokenIdentity(accessToken)
+	if err != nil {
+		return nil, &APIError{Operation: "get an IAM token", Err: err}
+	}
+	iamID := identity.IAMID
+	if accountID == "" {
+		accountID = identity.Account.BSS
+	}
+
+	bulk := authzBulkRequest{}
+	for _, action := range actions {
+		bulk.Requests = append(bulk.Requests, authzRequest{
+			Subject: authzAttributes{Attributes: map[string]string{"id": iamID}},
+			Action:  action,
+			Resource: authzAttributes{Attributes: map[string]string{
+				"serviceName":     vpcServiceName,
+				"accountId":       accountID,
+				"resourceGroupId": resourceGroupID,
+			}},
+		})
+	}
+	body, err := json.Marshal(bulk)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(callCtx, http.MethodPost, strings.TrimSuffix(iamEndpoint, "/")+authzBulkPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, &APIError{Operation: "check IAM permissions", Err: err}
+	}
+	defer response.Body.Close()
+	transactionID := response.Header.Get(TransactionIDHeader)
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, &APIError{Operation: "check IAM permissions", StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	// Individual checks may fail while the bulk request succeeds, IAM then answers with 207.
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusMultiStatus {
+		return nil, &APIError{Operation: "check IAM permissions", StatusCode: response.StatusCode, TransactionID: transactionID,
+			Err: fmt.Errorf("unexpected response: %s", strings.TrimSpace(string(responseBody)))}
+	}
+
+	var decisions authzBulkResponse
+	if err := json.Unmarshal(responseBody, &decisions); err != nil {
+		return nil, &APIError{Operation: "check IAM permissions", StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	if len(decisions.Responses) != len(actions) {
+		return nil, &APIError{Operation: "check IAM permissions", StatusCode: response.StatusCode, TransactionID: transactionID,
+			Err: fmt.Errorf("got %d decisions for %d actions", len(decisions.Responses), len(actions))}
+	}
+	var missing []string
+	for i, decision := range decisions.Responses {
+		if decision.AuthorizationDecision == nil {
+			message := "no decision"
+			if decision.Error != nil {
+				message = decision.Error.Message
+			}
+			return nil, &APIError{Operation: "check IAM permissions", StatusCode: response.StatusCode, TransactionID: transactionID,
+				Err: fmt.Errorf("action %s: %s", actions[i], message)}
+		}
+		if !decision.AuthorizationDecision.Permitted {
+			missing = append(missing, actions[i])
+		}
+	}
+	return missing, nil
+}
+
+// tokenIdentity holds the claims of an IAM access token naming the identity it was issued to.
+type tokenIdentity struct {
+	IAMID   string `json:"iam_id"`
+	Account struct {
+		BSS string `json:"bss"`
+	} `json:"account"`
+}
+
+// parseTokenIdentity returns the identity an IAM access token was issued to.
+// The token comes straight from IAM, so its signature is not verified.
+func parseTokenIdentity(accessToken string) (*tokenIdentity, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("IAM access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("IAM access token payload is invalid: %w", err)
+	}
+	identity := &tokenIdentity{}
+	if err := json.Unmarshal(payload, identity); err != nil {
+		return nil, fmt.Errorf("IAM access token payload is invalid: %w", err)
+	}
+	if identity.IAMID == "" {
+		return nil, fmt.Errorf("IAM access token has no iam_id claim")
+	}
+	return identity, nil
+}
@@ -0,0 +1,154 @@
+package ibmcloud
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const (
+	testIAMID           = "iam-ServiceId-1234"
+	testAccountID       = "account-1234"
+	testResourceGroupID = "1234567890abcdef1234567890abcdef"
+)
+
+// newIAMServer returns a stand-in of IAM issuing tokens for testIAMID and granting the
+// actions in permitted on testResourceGroupID. authzStatus overrides the status of the
+// authorization API when it is not 0.
+func newIAMServer(t *testing.T, permitted map[string]bool, authzStatus int) *httptest.Server {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iam_id":"` + testIAMID + `","account":{"bss":"` + testAccountID + `"}}`))
+	accessToken := "eyJhbGciOiJub25lIn0." + payload + ".c2lnbmF0dXJl"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(TransactionIDHeader, "txn-authz")
+		switch r.URL.Path {
+		case "/identity/token":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": accessToken,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"expiration":   4102444800,
+			})
+		case authzBulkPath:
+			if r.Header.Get("Authorization") != "Bearer "+accessToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if authzStatus != 0 {
+				w.WriteHeader(authzStatus)
+				w.Write([]byte(`{"message":"failure"}`))
+				return
+			}
+			var bulk authzBulkRequest
+			if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+				t.Errorf("invalid authorization request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var responses []map[string]interface{}
+			for _, request := range bulk.Requests {
+				allowed := request.Subject.Attributes["id"] == testIAMID &&
+					request.Resource.Attributes["serviceName"] == vpcServiceName &&
+					request.Resource.Attributes["accountId"] == testAccountID &&
+					request.Resource.Attributes["resourceGroupId"] == testResourceGroupID &&
+					permitted[request.Action]
+				responses = append(responses, map[string]interface{}{
+					"status":                "200",
+					"authorizationDecision": map[string]interface{}{"permitted": allowed},
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"responses": responses})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestMissingPermissions(t *testing.T) {
+	actions := []string{"is.volume.volume.create", "is.volume.volume.delete", "is.snapshot.snapshot.create"}
+	tests := []struct {
+		name            string
+		permitted       map[string]bool
+		accountID       string
+		resourceGroupID string
+		expected        []string
+	}{
+		{
+			name:            "all permitted",
+			permitted:       map[string]bool{"is.volume.volume.create": true, "is.volume.volume.delete": true, "is.snapshot.snapshot.create": true},
+			accountID:       testAccountID,
+			resourceGroupID: testResourceGroupID,
+		},
+		{
+			name:            "account of the identity",
+			permitted:       map[string]bool{"is.volume.volume.create": true, "is.volume.volume.delete": true, "is.snapshot.snapshot.create": true},
+			resourceGroupID: testResourceGroupID,
+		},
+		{
+			name:            "snapshot missing",
+			permitted:       map[string]bool{"is.volume.volume.create": true, "is.volume.volume.delete": true},
+			accountID:       testAccountID,
+			resourceGroupID: testResourceGroupID,
+			expected:        []string{"is.snapshot.snapshot.create"},
+		},
+		{
+			name:            "other resource group",
+			permitted:       map[string]bool{"is.volume.volume.create": true, "is.volume.volume.delete": true, "is.snapshot.snapshot.create": true},
+			accountID:       testAccountID,
+			resourceGroupID: "other",
+			expected:        actions,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newIAMServer(t, tt.permitted, 0)
+			defer server.Close()
+			missing, err := MissingPermissions(context.TODO(), server.Client(), "api-key", server.URL, tt.accountID, tt.resourceGroupID, actions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(missing, tt.expected) {
+				t.Errorf("MissingPermissions() = %v, expected %v", missing, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMissingPermissionsError(t *testing.T) {
+	tests := []struct {
+		name              string
+		status            int
+		expectedTransient bool
+	}{
+		{
+			name:   "forbidden",
+			status: http.StatusForbidden,
+		},
+		{
+			name:              "unavailable",
+			status:            http.StatusServiceUnavailable,
+			expectedTransient: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newIAMServer(t, nil, tt.status)
+			defer server.Close()
+			_, err := MissingPermissions(context.TODO(), server.Client(), "api-key", server.URL, testAccountID, testResourceGroupID, []string{"is.volume.volume.create"})
+			apiErr, ok := err.(*APIError)
+			if !ok {
+				t.Fatalf("expected *APIError, got %v", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.TransactionID != "txn-authz" {
+				t.Errorf("got status %d and transaction ID %q", apiErr.StatusCode, apiErr.TransactionID)
+			}
+			if apiErr.Transient() != tt.expectedTransient {
+				t.Errorf("Transient() = %v, expected %v", apiErr.Transient(), tt.expectedTransient)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ibmcloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	crnPrefix       = "crn"
+	crnVersion      = "v1"
+	crnSegments     = 10
+	accountScopeTag = "a/"
+
+	// KeyProtectServiceName and HPCSServiceName are the services of the root keys
+	// that can encrypt VPC block volumes.
+	KeyProtectServiceName = "kms"
+	HPCSServiceName       = "hs-crypto"
+	keyResourceType       = "key"
+)
+
+// CRN is a parsed IBM Cloud Resource Name:
+// crn:v1:<cname>:<ctype>:<service-name>:<location>:<scope>:<service-instance>:<resource-type>:<resource>
+type CRN struct {
+	CName           string
+	CType           string
+	ServiceName     string
+	Location        string
+	Scope           string
+	ServiceInstance string
+	ResourceType    string
+	Resource        string
+}
+
+// ParseCRN splits crn into its segments.
+func ParseCRN(crn string) (*CRN, error) {
+	segments := strings.Split(crn, ":")
+	if len(segments) != crnSegments {
+		return nil, fmt.Errorf("CRN %q must have %d segments separated by colons, got %d", crn, crnSegments, len(segments))
+	}
+	if segments[0] != crnPrefix || segments[1] != crnVersion {
+		return nil, fmt.Errorf("CRN %q must start with %s:%s", crn, crnPrefix, crnVersion)
+	}
+	return &CRN{
+		CName:           segments[2],
+		CType:           segments[3],
+		ServiceName:     segments[4],
+		Location:        segments[5],
+		Scope:           segments[6],
+		ServiceInstance: segments[7],
+		ResourceType:    segments[8],
+		Resource:        segments[9],
+	}, nil
+}
+
+// String returns the CRN in its text form.
+func (c *CRN) String() string {
+	return strings.Join([]string{crnPrefix, crnVersion, c.CName, c.CType, c.ServiceName, c.Location, c.Scope, c.ServiceInstance, c.ResourceType, c.Resource}, ":")
+}
+
+// AccountID returns the account of an account-scoped CRN, or an empty string.
+func (c *CRN) AccountID() string {
+	if !strings.HasPrefix(c.Scope, accountScopeTag) {
+		return ""
+	}
+	return strings.TrimPrefix(c.Scope, accountScopeTag)
+}
+
+// EncryptionKeyCRNError is an encryption key CRN that cannot be used by the driver.
+type EncryptionKeyCRNError struct {
+	CRN     string
+	Message string
+}
+
+func (e *EncryptionKeyCRNError) Error() string {
+	return fmt.Sprintf("invalid encryption key CRN %q: %s", e.CRN, e.Message)
+}
+
+// ParseEncryptionKeyCRN parses the CRN of a Key Protect or Hyper Protect Crypto Services
+// root key and checks that it names a key of an instance in an account and a region.
+func ParseEncryptionKeyCRN(crn string) (*CRN, error) {
+	parsed, err := ParseCRN(crn)
+	if err != nil {
+		return nil, &EncryptionKeyCRNError{CRN: crn, Message: err.Error()}
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return &EncryptionKeyCRNError{CRN: crn, Message: fmt.Sprintf(format, args...)}
+	}
+	switch {
+	case parsed.ServiceName != KeyProtectServiceName && parsed.ServiceName != HPCSServiceName:
+		return nil, invalid("service must be %s or %s, got %q", KeyProtectServiceName, HPCSServiceName, parsed.ServiceName)
+	case parsed.Location == "":
+		return nil, invalid("region is missing")
+	case parsed.AccountID() == "":
+		return nil, invalid("scope must be an account, %s<account ID>, got %q", accountScopeTag, parsed.Scope)
+	case parsed.ServiceInstance == "":
+		return nil, invalid("service instance is missing")
+	case parsed.ResourceType != keyResourceType:
+		return nil, invalid("resource type must be %s, got %q", keyResourceType, parsed.ResourceType)
+	case parsed.Resource == "":
+		return nil, invalid("key ID is missing")
+	}
+	return parsed, nil
+}
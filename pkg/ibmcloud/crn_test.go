@@ -0,0 +1,91 @@
+package ibmcloud
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseEncryptionKeyCRN(t *testing.T) {
+	crn, err := ParseEncryptionKeyCRN("crn:v1:bluemix:public:hs-crypto:eu-de:a/18240e5ed3f647cb96ca52dc92d9addd:d5e11420-3f0f-447a-92c0-13c978cf9096:key:c4a72f5b-9412-4ce8-80a4-2acb1987f3ba")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := CRN{
+		CName:           "bluemix",
+		CType:           "public",
+		ServiceName:     HPCSServiceName,
+		Location:        "eu-de",
+		Scope:           "a/18240e5ed3f647cb96ca52dc92d9addd",
+		ServiceInstance: "d5e11420-3f0f-447a-92c0-13c978cf9096",
+		ResourceType:    "key",
+		Resource:        "c4a72f5b-9412-4ce8-80a4-2acb1987f3ba",
+	}
+	if crn.String() != "crn:v1:bluemix:public:hs-crypto:eu-de:a/18240e5ed3f647cb96ca52dc92d9addd:d5e11420-3f0f-447a-92c0-13c978cf9096:key:c4a72f5b-9412-4ce8-80a4-2acb1987f3ba" {
+		t.Errorf("String() = %q", crn.String())
+	}
+	if *crn != expected {
+		t.Errorf("ParseEncryptionKeyCRN() = %+v, expected %+v", *crn, expected)
+	}
+	if crn.AccountID() != "18240e5ed3f647cb96ca52dc92d9addd" {
+		t.Errorf("AccountID() = %q", crn.AccountID())
+	}
+}
+
+func TestParseEncryptionKeyCRNErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		crn      string
+		expected string
+	}{
+		{
+			name:     "not a CRN",
+			crn:      "my-key",
+			expected: "segments",
+		},
+		{
+			name:     "wrong version",
+			crn:      "crn:v2:bluemix:public:kms:us-south:a/account:instance:key:key-id",
+			expected: "must start with crn:v1",
+		},
+		{
+			name:     "other service",
+			crn:      "crn:v1:bluemix:public:cloud-object-storage:us-south:a/account:instance:key:key-id",
+			expected: "service must be kms or hs-crypto",
+		},
+		{
+			name:     "no region",
+			crn:      "crn:v1:bluemix:public:kms::a/account:instance:key:key-id",
+			expected: "region",
+		},
+		{
+			name:     "no account",
+			crn:      "crn:v1:bluemix:public:kms:us-south:o/org:instance:key:key-id",
+			expected: "scope must be an account",
+		},
+		{
+			name:     "no instance",
+			crn:      "crn:v1:bluemix:public:kms:us-south:a/account::key:key-id",
+			expected: "service instance",
+		},
+		{
+			name:     "not a key",
+			crn:      "crn:v1:bluemix:public:kms:us-south:a/account:instance:policy:key-id",
+			expected: "resource type must be key",
+		},
+		{
+			name:     "no key",
+			crn:      "crn:v1:bluemix:public:kms:us-south:a/account:instance:key:",
+			expected: "key ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseEncryptionKeyCRN(tt.crn)
+			var crnErr *EncryptionKeyCRNError
+			if !errors.As(err, &crnErr) || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("ParseEncryptionKeyCRN() got error %v, expected one containing %q", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ibmcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// TransactionIDHeader is the response header with the transaction ID of an IBM Cloud API
+// call, which IBM Cloud support needs to trace a failed request.
+const TransactionIDHeader = "Transaction-Id"
+
+// APIError is a failed call to an IBM Cloud API.
+type APIError struct {
+	// Operation describes the failed call, e.g. "list resource groups".
+	Operation string
+	// StatusCode is the HTTP status of the response, or 0 when no response was received.
+	StatusCode    int
+	TransactionID string
+	Err           error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("failed to %s: %v", e.Operation, e.Err)
+	}
+	return fmt.Sprintf("failed to %s with status %d, transaction ID %q: %v", e.Operation, e.StatusCode, e.TransactionID, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Transient returns true when the call may succeed if it is retried, i.e. when no response
+// was received (network error, timeout) or the service failed or throttled the request.
+// Rejected credentials, missing permissions and missing resources are permanent.
+func (e *APIError) Transient() bool {
+	if errors.Is(e.Err, context.Canceled) {
+		return false
+	}
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// NewAPIError wraps err returned by an IBM Cloud SDK call for operation. response is the
+// one returned with err and may be nil, in which case the status and transaction ID are
+// taken from the HTTP problem in err, if any, e.g. when IAM refused to issue a token.
+func NewAPIError(operation string, response *core.DetailedResponse, err error) *APIError {
+	if response == nil {
+		var httpProblem *core.HTTPProblem
+		if errors.As(err, &httpProblem) {
+			response = httpProblem.Response
+		}
+	}
+	apiErr := &APIError{Operation: operation, Err: err}
+	if response != nil {
+		apiErr.StatusCode = response.StatusCode
+		if response.Headers != nil {
+			apiErr.TransactionID = response.Headers.Get(TransactionIDHeader)
+		}
+	}
+	return apiErr
+}
+
+// IsTransient returns true when err is or wraps a transient APIError.
+func IsTransient(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Transient()
+}
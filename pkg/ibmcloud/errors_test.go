@@ -0,0 +1,93 @@
+package ibmcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+func TestNewAPIError(t *testing.T) {
+	headers := http.Header{}
+	headers.Set(TransactionIDHeader, "txn-1234")
+	tests := []struct {
+		name                  string
+		response              *core.DetailedResponse
+		err                   error
+		expectedStatus        int
+		expectedTransactionID string
+		expectedTransient     bool
+	}{
+		{
+			name:              "no response",
+			err:               errors.New("connection refused"),
+			expectedTransient: true,
+		},
+		{
+			name:              "timeout",
+			err:               fmt.Errorf("request failed: %w", context.DeadlineExceeded),
+			expectedTransient: true,
+		},
+		{
+			name:              "cancelled",
+			err:               fmt.Errorf("request failed: %w", context.Canceled),
+			expectedTransient: false,
+		},
+		{
+			name:                  "service unavailable",
+			response:              &core.DetailedResponse{StatusCode: http.StatusServiceUnavailable, Headers: headers},
+			err:                   errors.New("unavailable"),
+			expectedStatus:        http.StatusServiceUnavailable,
+			expectedTransactionID: "txn-1234",
+			expectedTransient:     true,
+		},
+		{
+			name:                  "too many requests",
+			response:              &core.DetailedResponse{StatusCode: http.StatusTooManyRequests, Headers: headers},
+			err:                   errors.New("throttled"),
+			expectedStatus:        http.StatusTooManyRequests,
+			expectedTransactionID: "txn-1234",
+			expectedTransient:     true,
+		},
+		{
+			name:                  "unauthorized",
+			response:              &core.DetailedResponse{StatusCode: http.StatusUnauthorized, Headers: headers},
+			err:                   errors.New("unauthorized"),
+			expectedStatus:        http.StatusUnauthorized,
+			expectedTransactionID: "txn-1234",
+		},
+		{
+			name:                  "forbidden",
+			response:              &core.DetailedResponse{StatusCode: http.StatusForbidden, Headers: headers},
+			err:                   errors.New("forbidden"),
+			expectedStatus:        http.StatusForbidden,
+			expectedTransactionID: "txn-1234",
+		},
+		{
+			name:           "not found",
+			response:       &core.DetailedResponse{StatusCode: http.StatusNotFound},
+			err:            errors.New("not found"),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := NewAPIError("test operation", tt.response, tt.err)
+			if apiErr.StatusCode != tt.expectedStatus || apiErr.TransactionID != tt.expectedTransactionID {
+				t.Errorf("NewAPIError() got status %d and transaction ID %q, expected %d and %q", apiErr.StatusCode, apiErr.TransactionID, tt.expectedStatus, tt.expectedTransactionID)
+			}
+			if transient := IsTransient(fmt.Errorf("wrapped: %w", apiErr)); transient != tt.expectedTransient {
+				t.Errorf("IsTransient() got %v, expected %v", transient, tt.expectedTransient)
+			}
+			if !errors.Is(apiErr, tt.err) {
+				t.Errorf("NewAPIError() does not wrap %v", tt.err)
+			}
+		})
+	}
+	if IsTransient(errors.New("plain error")) {
+		t.Errorf("IsTransient() expected false for a plain error")
+	}
+}
@@ -0,0 +1,88 @@
+package ibmcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// iamTokenPath is the path of the token API of IAM, relative to the IAM endpoint.
+	iamTokenPath = "/identity/token"
+	// iamAPIKeyGrantType is the grant type exchanging an API key for an access token.
+	iamAPIKeyGrantType = "urn:ibm:params:oauth:grant-type:apikey"
+)
+
+// IAMTokenError is the reason given by IAM for refusing to issue a token.
+type IAMTokenError struct {
+	ErrorCode string
+	Message   string
+}
+
+func (e *IAMTokenError) Error() string {
+	if e.ErrorCode == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.Message)
+}
+
+// RequestIAMToken exchanges apiKey for an IAM access token at iamEndpoint. The request is
+// bound to ctx and to CallTimeout. Failed calls are reported as *APIError, wrapping an
+// *IAMTokenError when IAM answered with an error.
+func RequestIAMToken(ctx context.Context, client *http.Client, apiKey, iamEndpoint string) (string, error) {
+	const operation = "get an IAM token"
+	form := url.Values{
+		"grant_type":    {iamAPIKeyGrantType},
+		"apikey":        {apiKey},
+		"response_type": {"cloud_iam"},
+	}
+	tokenURL := strings.TrimSuffix(iamEndpoint, "/")
+	if !strings.HasSuffix(tokenURL, iamTokenPath) {
+		tokenURL += iamTokenPath
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(callCtx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return "", &APIError{Operation: operation, Err: err}
+	}
+	defer response.Body.Close()
+	transactionID := response.Header.Get(TransactionIDHeader)
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	if response.StatusCode != http.StatusOK {
+		var problem struct {
+			ErrorCode    string `json:"errorCode"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+		tokenErr := &IAMTokenError{Message: strings.TrimSpace(string(body))}
+		if json.Unmarshal(body, &problem) == nil && problem.ErrorCode != "" {
+			tokenErr.ErrorCode, tokenErr.Message = problem.ErrorCode, problem.ErrorMessage
+		}
+		return "", &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: tokenErr}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	if token.AccessToken == "" {
+		return "", &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: fmt.Errorf("no access token in the response")}
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,100 @@
+package ibmcloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestIAMToken(t *testing.T) {
+	tests := []struct {
+		name              string
+		statusCode        int
+		body              string
+		expectedToken     string
+		expectedTokenErr  *IAMTokenError
+		expectedTransient bool
+	}{
+		{
+			name:          "token issued",
+			statusCode:    http.StatusOK,
+			body:          `{"access_token":"token","token_type":"Bearer","expires_in":3600}`,
+			expectedToken: "token",
+		},
+		{
+			name:             "key rejected",
+			statusCode:       http.StatusBadRequest,
+			body:             `{"errorCode":"BXNIM0415E","errorMessage":"Provided API key could not be found."}`,
+			expectedTokenErr: &IAMTokenError{ErrorCode: "BXNIM0415E", Message: "Provided API key could not be found."},
+		},
+		{
+			name:              "outage without an IAM error",
+			statusCode:        http.StatusBadGateway,
+			body:              "bad gateway",
+			expectedTokenErr:  &IAMTokenError{Message: "bad gateway"},
+			expectedTransient: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != iamTokenPath {
+					t.Errorf("unexpected request path %s", r.URL.Path)
+				}
+				if err := r.ParseForm(); err != nil || r.Form.Get("apikey") != "api-key" || r.Form.Get("grant_type") != iamAPIKeyGrantType {
+					t.Errorf("unexpected token request: %v", r.Form)
+				}
+				w.Header().Set(TransactionIDHeader, "txn-iam")
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			token, err := RequestIAMToken(context.TODO(), server.Client(), "api-key", server.URL)
+			if tt.expectedTokenErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if token != tt.expectedToken {
+					t.Errorf("RequestIAMToken() = %q, expected %q", token, tt.expectedToken)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.statusCode || apiErr.TransactionID != "txn-iam" {
+				t.Fatalf("RequestIAMToken() error %v, expected an API error with status %d and a transaction ID", err, tt.statusCode)
+			}
+			var tokenErr *IAMTokenError
+			if !errors.As(err, &tokenErr) || *tokenErr != *tt.expectedTokenErr {
+				t.Errorf("RequestIAMToken() error %v, expected %+v", err, *tt.expectedTokenErr)
+			}
+			if IsTransient(err) != tt.expectedTransient {
+				t.Errorf("RequestIAMToken() error %v transient %v, expected %v", err, IsTransient(err), tt.expectedTransient)
+			}
+		})
+	}
+}
+
+func TestRequestIAMTokenContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// An unresponsive IAM must not outlive the sync context.
+	_, err := RequestIAMToken(ctx, http.DefaultClient, "api-key", server.URL)
+	if !IsTransient(err) {
+		t.Errorf("RequestIAMToken() expected a transient error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RequestIAMToken() returned after %s, expected it to honor the context", elapsed)
+	}
+}
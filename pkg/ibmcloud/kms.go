@@ -0,0 +1,210 @@
+package ibmcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// keyProtectEndpoint and hpcsEndpoint are the public key management endpoints of
+	// Key Protect in a region and of a Hyper Protect Crypto Services instance in a region.
+	keyProtectEndpoint = "https://%s.kms.cloud.ibm.com"
+	hpcsEndpoint       = "https://%s.api.%s.hs-crypto.appdomain.cloud"
+	// keysPath is the path of the keys of the key management API, relative to its endpoint.
+	keysPath = "/api/v2/keys/"
+	// policiesPath is the path of the IAM Policy Management API, relative to the IAM endpoint.
+	policiesPath = "/v1/policies"
+	// BlockStorageServiceName is the IAM service name of Block Storage for VPC, the source
+	// of the service-to-service authorization that lets it use root keys.
+	BlockStorageServiceName = "server-protect"
+	// readerRoleSuffix ends the ID of the Reader service role, the one granted by the
+	// service-to-service authorization.
+	readerRoleSuffix = ":serviceRole:Reader"
+	// keyStateActive is the state of a key that can wrap and unwrap data encryption keys.
+	keyStateActive = 1
+)
+
+// keyStates names the states of a Key Protect or Hyper Protect Crypto Services key.
+var keyStates = map[int]string{
+	0: "Pre-activation",
+	1: "Active",
+	2: "Suspended",
+	3: "Deactivated",
+	5: "Destroyed",
+}
+
+// Reasons of a RootKeyError.
+const (
+	RootKeyNotFound             = "KeyNotFound"
+	RootKeyNotActive            = "KeyNotActive"
+	RootKeyNotRootKey           = "NotRootKey"
+	RootKeyMissingAuthorization = "MissingAuthorization"
+)
+
+// RootKeyError is a root key that exists in a state or with an authorization that does not
+// allow Block Storage for VPC to encrypt volumes with it. Reason is one of the RootKey* constants.
+type RootKeyError struct {
+	CRN     string
+	Reason  string
+	Message string
+}
+
+func (e *RootKeyError) Error() string {
+	return fmt.Sprintf("root key %q cannot be used: %s", e.CRN, e.Message)
+}
+
+type keysResponse struct {
+	Resources []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		State       int    `json:"state"`
+		Extractable bool   `json:"extractable"`
+	} `json:"resources"`
+}
+
+type policiesResponse struct {
+	Policies []struct {
+		Type      string             `json:"type"`
+		Subjects  []policyAttributes `json:"subjects"`
+		Resources []policyAttributes `json:"resources"`
+		Roles     []struct {
+			RoleID string `json:"role_id"`
+		} `json:"roles"`
+	} `json:"policies"`
+}
+
+type policyAttributes struct {
+	Attributes []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"attributes"`
+}
+
+// value returns the value of attribute name, or an empty string.
+func (a policyAttributes) value(name string) string {
+	for _, attribute := range a.Attributes {
+		if attribute.Name == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+// KeyManagementEndpoint returns the public key management endpoint of the instance of key,
+// which must be a CRN returned by ParseEncryptionKeyCRN.
+func KeyManagementEndpoint(key *CRN) string {
+	if key.ServiceName == HPCSServiceName {
+		return fmt.Sprintf(hpcsEndpoint, key.ServiceInstance, key.Location)
+	}
+	return fmt.Sprintf(keyProtectEndpoint, key.Location)
+}
+
+// CheckRootKey checks with the identity owning apiKey that key, a CRN returned by
+// ParseEncryptionKeyCRN, is an active root key of the key management service at keyEndpoint
+// and that Block Storage for VPC is authorized to use it in IAM at iamEndpoint. A key that
+// cannot be used is reported as *RootKeyError, failed calls as *APIError.
+func CheckRootKey(ctx context.Context, client *http.Client, apiKey, iamEndpoint, keyEndpoint string, key *CRN) error {
+	accessToken, err := RequestIAMToken(ctx, client, apiKey, iamEndpoint)
+	if err != nil {
+		return err
+	}
+	unusable := func(reason, format string, args ...interface{}) error {
+		return &RootKeyError{CRN: key.String(), Reason: reason, Message: fmt.Sprintf(format, args...)}
+	}
+
+	var keys keysResponse
+	status, err := getJSON(ctx, client, "get the root key", strings.TrimSuffix(keyEndpoint, "/")+keysPath+url.PathEscape(key.Resource), map[string]string{
+		"Authorization":    "Bearer " + accessToken,
+		"Bluemix-Instance": key.ServiceInstance,
+	}, &keys)
+	switch {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return unusable(RootKeyNotFound, "key %s does not exist in instance %s", key.Resource, key.ServiceInstance)
+	case err != nil:
+		return err
+	case len(keys.Resources) != 1:
+		return &APIError{Operation: "get the root key", StatusCode: status, Err: fmt.Errorf("got %d keys", len(keys.Resources))}
+	}
+	found := keys.Resources[0]
+	if found.State != keyStateActive {
+		state, ok := keyStates[found.State]
+		if !ok {
+			state = fmt.Sprintf("%d", found.State)
+		}
+		return unusable(RootKeyNotActive, "key %s is in state %s, not Active", key.Resource, state)
+	}
+	if found.Extractable {
+		return unusable(RootKeyNotRootKey, "key %s is a standard key, not a root key", key.Resource)
+	}
+
+	query := url.Values{"account_id": {key.AccountID()}, "type": {"authorization"}}
+	var policies policiesResponse
+	if _, err := getJSON(ctx, client, "list IAM authorizations", strings.TrimSuffix(iamEndpoint, "/")+policiesPath+"?"+query.Encode(), map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}, &policies); err != nil {
+		return err
+	}
+	for _, policy := range policies.Policies {
+		if policy.Type != "authorization" || len(policy.Subjects) == 0 || len(policy.Resources) == 0 {
+			continue
+		}
+		if policy.Subjects[0].value("serviceName") != BlockStorageServiceName {
+			continue
+		}
+		resource := policy.Resources[0]
+		if resource.value("serviceName") != key.ServiceName {
+			continue
+		}
+		if instance := resource.value("serviceInstance"); instance != "" && instance != key.ServiceInstance {
+			continue
+		}
+		if id := resource.value("resource"); id != "" && id != key.Resource {
+			continue
+		}
+		for _, role := range policy.Roles {
+			if strings.HasSuffix(role.RoleID, readerRoleSuffix) {
+				return nil
+			}
+		}
+	}
+	return unusable(RootKeyMissingAuthorization, "Block Storage for VPC (%s) is not authorized to read keys of %s instance %s",
+		BlockStorageServiceName, key.ServiceName, key.ServiceInstance)
+}
+
+// getJSON sends a GET request for operation to rawURL with headers and decodes the JSON
+// response into out. It returns the status of the response, if any, with the error.
+func getJSON(ctx context.Context, client *http.Client, operation, rawURL string, headers map[string]string, out interface{}) (int, error) {
+	callCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(callCtx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, &APIError{Operation: operation, Err: err}
+	}
+	defer response.Body.Close()
+	transactionID := response.Header.Get(TransactionIDHeader)
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	if response.StatusCode != http.StatusOK {
+		return response.StatusCode, &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID,
+			Err: fmt.Errorf("unexpected response: %s", strings.TrimSpace(string(body)))}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return response.StatusCode, &APIError{Operation: operation, StatusCode: response.StatusCode, TransactionID: transactionID, Err: err}
+	}
+	return response.StatusCode, nil
+}
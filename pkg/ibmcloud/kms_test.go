@@ -0,0 +1,177 @@
+package ibmcloud
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testKeyInstance = "d5e11420-3f0f-447a-92c0-13c978cf9096"
+	testKeyID       = "c4a72f5b-9412-4ce8-80a4-2acb1987f3ba"
+)
+
+// fakeKeyProtect is a stand-in of IAM and Key Protect serving testKeyID of testKeyInstance
+// and the service-to-service authorizations of testAccountID.
+type fakeKeyProtect struct {
+	// key is the key returned for testKeyID, nil when it does not exist.
+	key map[string]interface{}
+	// authorizations are the authorization policies of the account.
+	authorizations []map[string]interface{}
+	// keyStatus overrides the status of the key API when it is not 0.
+	keyStatus int
+}
+
+func (f *fakeKeyProtect) server(t *testing.T) *httptest.Server {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iam_id":"` + testIAMID + `"}`))
+	accessToken := "eyJhbGciOiJub25lIn0." + payload + ".c2lnbmF0dXJl"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(TransactionIDHeader, "txn-kms")
+		if r.URL.Path == "/identity/token" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": accessToken,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"expiration":   4102444800,
+			})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+accessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case keysPath + testKeyID:
+			if r.Header.Get("Bluemix-Instance") != testKeyInstance {
+				t.Errorf("unexpected instance %q", r.Header.Get("Bluemix-Instance"))
+			}
+			if f.keyStatus != 0 {
+				w.WriteHeader(f.keyStatus)
+				w.Write([]byte(`{"message":"failure"}`))
+				return
+			}
+			if f.key == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"resources": []interface{}{f.key}})
+		case policiesPath:
+			if r.URL.Query().Get("account_id") != testAccountID || r.URL.Query().Get("type") != "authorization" {
+				t.Errorf("unexpected policy query %q", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"policies": f.authorizations})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func rootKey(state int, extractable bool) map[string]interface{} {
+	return map[string]interface{}{"id": testKeyID, "name": "root", "state": state, "extractable": extractable}
+}
+
+func authorization(source, target, instance string) map[string]interface{} {
+	resource := []map[string]string{{"name": "accountId", "value": testAccountID}, {"name": "serviceName", "value": target}}
+	if instance != "" {
+		resource = append(resource, map[string]string{"name": "serviceInstance", "value": instance})
+	}
+	return map[string]interface{}{
+		"type":      "authorization",
+		"subjects":  []interface{}{map[string]interface{}{"attributes": []map[string]string{{"name": "serviceName", "value": source}}}},
+		"resources": []interface{}{map[string]interface{}{"attributes": resource}},
+		"roles":     []interface{}{map[string]string{"role_id": "crn:v1:bluemix:public:iam::::serviceRole:Reader"}},
+	}
+}
+
+func TestCheckRootKey(t *testing.T) {
+	blockStorage := authorization(BlockStorageServiceName, KeyProtectServiceName, testKeyInstance)
+	tests := []struct {
+		name           string
+		kms            fakeKeyProtect
+		expectedReason string
+		expectedStatus int
+	}{
+		{
+			name: "usable root key",
+			kms:  fakeKeyProtect{key: rootKey(1, false), authorizations: []map[string]interface{}{blockStorage}},
+		},
+		{
+			name: "authorization for all instances",
+			kms:  fakeKeyProtect{key: rootKey(1, false), authorizations: []map[string]interface{}{authorization(BlockStorageServiceName, KeyProtectServiceName, "")}},
+		},
+		{
+			name:           "missing key",
+			kms:            fakeKeyProtect{authorizations: []map[string]interface{}{blockStorage}},
+			expectedReason: RootKeyNotFound,
+		},
+		{
+			name:           "destroyed key",
+			kms:            fakeKeyProtect{key: rootKey(5, false), authorizations: []map[string]interface{}{blockStorage}},
+			expectedReason: RootKeyNotActive,
+		},
+		{
+			name:           "standard key",
+			kms:            fakeKeyProtect{key: rootKey(1, true), authorizations: []map[string]interface{}{blockStorage}},
+			expectedReason: RootKeyNotRootKey,
+		},
+		{
+			name: "authorization of another service or instance",
+			kms: fakeKeyProtect{key: rootKey(1, false), authorizations: []map[string]interface{}{
+				authorization("is", KeyProtectServiceName, testKeyInstance),
+				authorization(BlockStorageServiceName, KeyProtectServiceName, "other-instance"),
+			}},
+			expectedReason: RootKeyMissingAuthorization,
+		},
+		{
+			name:           "key API failure",
+			kms:            fakeKeyProtect{keyStatus: http.StatusForbidden},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.kms.server(t)
+			defer server.Close()
+			key, err := ParseEncryptionKeyCRN("crn:v1:bluemix:public:kms:us-south:a/" + testAccountID + ":" + testKeyInstance + ":key:" + testKeyID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = CheckRootKey(context.TODO(), server.Client(), "apikey", server.URL, server.URL, key)
+			var keyErr *RootKeyError
+			var apiErr *APIError
+			switch {
+			case tt.expectedReason != "":
+				if !errors.As(err, &keyErr) || keyErr.Reason != tt.expectedReason {
+					t.Errorf("CheckRootKey() got error %v, expected reason %s", err, tt.expectedReason)
+				}
+			case tt.expectedStatus != 0:
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.expectedStatus || apiErr.TransactionID != "txn-kms" {
+					t.Errorf("CheckRootKey() got error %v, expected an API error with status %d", err, tt.expectedStatus)
+				}
+			case err != nil:
+				t.Errorf("CheckRootKey() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyManagementEndpoint(t *testing.T) {
+	for crn, expected := range map[string]string{
+		"crn:v1:bluemix:public:kms:eu-de:a/account:instance:key:key-id":       "https://eu-de.kms.cloud.ibm.com",
+		"crn:v1:bluemix:public:hs-crypto:eu-de:a/account:instance:key:key-id": "https://instance.api.eu-de.hs-crypto.appdomain.cloud",
+	} {
+		key, err := ParseEncryptionKeyCRN(crn)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if endpoint := KeyManagementEndpoint(key); endpoint != expected {
+			t.Errorf("KeyManagementEndpoint(%s) = %s, expected %s", crn, endpoint, expected)
+		}
+	}
+}
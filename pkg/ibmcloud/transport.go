@@ -0,0 +1,125 @@
+package ibmcloud
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"golang.org/x/net/http/httpproxy"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	// CallTimeout bounds every request of the operator to the IBM Cloud APIs, so that an
+	// unresponsive endpoint cannot block a controller.
+	CallTimeout = 30 * time.Second
+	// proxyConfigName is the name of the cluster-wide Proxy object.
+	proxyConfigName = "cluster"
+	// TrustedCABundleKey is the key of the PEM bundle that the cluster network operator
+	// injects into ConfigMaps labeled with config.openshift.io/inject-trusted-cabundle.
+	TrustedCABundleKey = "ca-bundle.crt"
+)
+
+// NewHTTPClient returns an HTTP client for the IBM Cloud APIs that sends requests through
+// the cluster-wide proxy and trusts caBundle in addition to the system roots. Without
+// proxy, the proxy environment variables of the operator are used. caBundle may be empty.
+func NewHTTPClient(proxy *configv1.Proxy, caBundle []byte) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != nil {
+		proxyConfig := &httpproxy.Config{
+			HTTPProxy:  proxy.Status.HTTPProxy,
+			HTTPSProxy: proxy.Status.HTTPSProxy,
+			NoProxy:    proxy.Status.NoProxy,
+		}
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if len(caBundle) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("configmap %s key %s does not contain any valid PEM certificate", util.TrustedCAConfigMap, TrustedCABundleKey)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	return &http.Client{Transport: transport, Timeout: CallTimeout}, nil
+}
+
+// HTTPClientCache returns the HTTP client of NewHTTPClient for the cluster Proxy and the
+// trusted CA bundle ConfigMap in the operator namespace, either of which may not exist. The
+// client, and so its connection pool, is reused until the proxy or the CA bundle changes.
+type HTTPClientCache struct {
+	proxyLister     configlisters.ProxyLister
+	configMapLister corelisters.ConfigMapLister
+
+	lock sync.Mutex
+	// key is the hash of the proxy and the CA bundle client was built for.
+	key    string
+	client *http.Client
+}
+
+// NewHTTPClientCache returns an HTTPClientCache reading the Proxy from proxyLister and the
+// trusted CA bundle from configMapLister.
+func NewHTTPClientCache(proxyLister configlisters.ProxyLister, configMapLister corelisters.ConfigMapLister) *HTTPClientCache {
+	return &HTTPClientCache{proxyLister: proxyLister, configMapLister: configMapLister}
+}
+
+// Get returns the client for the current proxy and CA bundle.
+func (c *HTTPClientCache) Get() (*http.Client, error) {
+	proxy, err := c.proxyLister.Get(proxyConfigName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		proxy = nil
+	}
+	var caBundle []byte
+	trustedCA, err := c.configMapLister.ConfigMaps(util.OperatorNamespace).Get(util.TrustedCAConfigMap)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+	} else {
+		caBundle = []byte(trustedCA.Data[TrustedCABundleKey])
+	}
+
+	key := []string{"", "", "", string(caBundle)}
+	if proxy != nil {
+		key[0], key[1], key[2] = proxy.Status.HTTPProxy, proxy.Status.HTTPSProxy, proxy.Status.NoProxy
+	}
+	hash := sha256.Sum256([]byte(strings.Join(key, "\n")))
+	clientKey := hex.EncodeToString(hash[:])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.client != nil && c.key == clientKey {
+		return c.client, nil
+	}
+	client, err := NewHTTPClient(proxy, caBundle)
+	if err != nil {
+		return nil, err
+	}
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	c.key, c.client = clientKey, client
+	return client, nil
+}
@@ -0,0 +1,173 @@
+package ibmcloud
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	k8v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// certificatePEM returns the PEM encoded certificate of a TLS test server.
+func certificatePEM(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func TestNewHTTPClientCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		caBundle    []byte
+		expectError bool
+	}{
+		{
+			name:        "no CA bundle",
+			expectError: true,
+		},
+		{
+			name:     "CA bundle with the server CA",
+			caBundle: certificatePEM(server),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(nil, tt.caBundle)
+			if err != nil {
+				t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+			}
+			resp, err := client.Get(server.URL)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Get() expected a TLS error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() unexpected error: %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestNewHTTPClientInvalidCABundle(t *testing.T) {
+	_, err := NewHTTPClient(nil, []byte("not a certificate"))
+	if err == nil || !strings.Contains(err.Error(), util.TrustedCAConfigMap) {
+		t.Errorf("NewHTTPClient() expected an error naming %s, got %v", util.TrustedCAConfigMap, err)
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxiedHosts []string
+	// The proxy stand-in answers plain HTTP requests in place of the target.
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxyServer.Close()
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	proxy := &configv1.Proxy{
+		ObjectMeta: metav1.ObjectMeta{Name: proxyConfigName},
+		Status: configv1.ProxyStatus{
+			HTTPProxy:  proxyServer.URL,
+			HTTPSProxy: proxyServer.URL,
+			NoProxy:    ".private.example.com",
+		},
+	}
+	client, err := NewHTTPClient(proxy, nil)
+	if err != nil {
+		t.Fatalf("NewHTTPClient() unexpected error: %v", err)
+	}
+	transport := client.Transport.(*http.Transport)
+
+	tests := []struct {
+		target        string
+		expectedProxy *url.URL
+	}{
+		{target: "http://iam.cloud.ibm.com/identity/token", expectedProxy: proxyURL},
+		{target: "https://us-south.iaas.cloud.ibm.com/v1/volumes", expectedProxy: proxyURL},
+		{target: "https://iam.private.example.com/identity/token", expectedProxy: nil},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+		actual, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("Proxy(%s) unexpected error: %v", tt.target, err)
+		}
+		if (actual == nil) != (tt.expectedProxy == nil) || (actual != nil && actual.String() != tt.expectedProxy.String()) {
+			t.Errorf("Proxy(%s) got %v, expected %v", tt.target, actual, tt.expectedProxy)
+		}
+	}
+
+	resp, err := client.Get("http://iam.cloud.ibm.com/identity/token")
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if len(proxiedHosts) != 1 || proxiedHosts[0] != "iam.cloud.ibm.com" {
+		t.Errorf("request was not sent through the proxy, proxied hosts: %v", proxiedHosts)
+	}
+}
+
+func TestHTTPClientCache(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	proxyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	clients := NewHTTPClientCache(configlisters.NewProxyLister(proxyIndexer), corelisters.NewConfigMapLister(configMapIndexer))
+
+	// Without the Proxy and the trusted CA bundle, the client only trusts the system roots.
+	client, err := clients.Get()
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatalf("Get() expected a TLS error without the trusted CA bundle")
+	}
+	if again, err := clients.Get(); err != nil || again != client {
+		t.Errorf("Get() expected the same client for the same proxy and CA bundle, got %p, %v", again, err)
+	}
+
+	configMapIndexer.Add(&k8v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: util.TrustedCAConfigMap, Namespace: util.OperatorNamespace},
+		Data:       map[string]string{TrustedCABundleKey: string(certificatePEM(server))},
+	})
+	proxyIndexer.Add(&configv1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: proxyConfigName}})
+	withCA, err := clients.Get()
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if withCA == client {
+		t.Fatalf("Get() expected a new client for the new CA bundle")
+	}
+	resp, err := withCA.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() unexpected error with the trusted CA bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	proxyIndexer.Update(&configv1.Proxy{
+		ObjectMeta: metav1.ObjectMeta{Name: proxyConfigName},
+		Status:     configv1.ProxyStatus{HTTPSProxy: "http://proxy.example.com:3128"},
+	})
+	if withProxy, err := clients.Get(); err != nil || withProxy == withCA {
+		t.Errorf("Get() expected a new client for the new proxy, got %p, %v", withProxy, err)
+	}
+}
@@ -0,0 +1,60 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/assets"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestCredentialsRequestAsset(t *testing.T) {
+	manifest, err := assets.ReadFile("credentials.yaml")
+	if err != nil {
+		t.Fatalf("failed to read credentials.yaml: %v", err)
+	}
+	cr := resourceread.ReadCredentialRequestsOrDie(manifest)
+	if cr.GetName() != util.CredentialsRequestName || cr.GetNamespace() != util.CredentialsRequestNamespace {
+		t.Errorf("CredentialsRequest is %s/%s, expected %s/%s", cr.GetNamespace(), cr.GetName(), util.CredentialsRequestNamespace, util.CredentialsRequestName)
+	}
+	secretName, _, _ := unstructured.NestedString(cr.Object, "spec", "secretRef", "name")
+	if secretName != util.CloudCredentialSecretName {
+		t.Errorf("CredentialsRequest provisions secret %q, expected %q", secretName, util.CloudCredentialSecretName)
+	}
+
+	// All service accounts using the credentials must be listed.
+	serviceAccounts, _, _ := unstructured.NestedStringSlice(cr.Object, "spec", "serviceAccountNames")
+	for _, file := range []string{"controller_sa.yaml", "node_sa.yaml"} {
+		saManifest, err := assets.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		sa := resourceread.ReadServiceAccountV1OrDie(saManifest)
+		if !sets.New(serviceAccounts...).Has(sa.Name) {
+			t.Errorf("CredentialsRequest service accounts %v do not include %s", serviceAccounts, sa.Name)
+		}
+	}
+
+	policies, _, _ := unstructured.NestedSlice(cr.Object, "spec", "providerSpec", "policies")
+	if len(policies) == 0 {
+		t.Errorf("CredentialsRequest has no IAM policies")
+	}
+	// The services the operator calls with the credentials must be granted.
+	services := sets.New[string]()
+	for _, policy := range policies {
+		attributes, _, _ := unstructured.NestedSlice(policy.(map[string]interface{}), "attributes")
+		for _, attribute := range attributes {
+			attribute := attribute.(map[string]interface{})
+			if attribute["name"] == "serviceName" {
+				services.Insert(attribute["value"].(string))
+			}
+		}
+	}
+	for _, service := range []string{"is", "kms", "hs-crypto", "iam-access-management"} {
+		if !services.Has(service) {
+			t.Errorf("CredentialsRequest has no policy for service %s, got %v", service, sets.List(services))
+		}
+	}
+}
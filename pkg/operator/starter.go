@@ -25,12 +25,15 @@ import (
 	opinformers "github.com/openshift/client-go/operator/informers/externalversions"
 	"github.com/openshift/ibm-vpc-block-csi-driver-operator/assets"
 	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/secret"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/storageclass"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/tunables"
 	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/csi/csicontrollerset"
 	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
 	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
 	goc "github.com/openshift/library-go/pkg/operator/genericoperatorclient"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
 )
@@ -38,8 +41,18 @@ import (
 const (
 	encryptionKeyParameter = "encryptionKey"
 	encryptedParameter     = "encrypted"
+	resourceGroupParameter = "resourceGroup"
+	regionParameter        = "region"
+	tagsParameter          = "tags"
 )
 
+// storageClassFiles are the StorageClasses installed in all regions.
+var storageClassFiles = []string{
+	"storageclass/vpc-block-10iopsTier-StorageClass.yaml",
+	"storageclass/vpc-block-5iopsTier-StorageClass.yaml",
+	"storageclass/vpc-block-custom-StorageClass.yaml",
+}
+
 func RunOperator(ctx context.Context, controllerConfig *controllercmd.ControllerContext) error {
 	// Create core clientset and informers
 	kubeClient := kubeclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, util.OperatorName))
@@ -54,7 +67,7 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return err
 	}
 
-	// Create config clientset and informer. This is used to get the cluster ID
+	// Create config clientset and informer. This is used to get the cluster ID and the IBM Cloud platform status
 	configClient := configclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, util.OperatorName))
 	configInformers := configinformers.NewSharedInformerFactory(configClient, util.Resync)
 
@@ -83,6 +96,34 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return err
 	}
 
+	// The hooks apply to all StorageClasses of the operator: built-in, profile and template ones.
+	// The parameter drift hook must be the last one, to compare the final StorageClass.
+	storageClassHooks := []csistorageclasscontroller.StorageClassHookFunc{
+		getEncryptionKeyHook(
+			operatorClient,
+			operatorInformers.Operator().V1().ClusterCSIDrivers().Lister(),
+			kubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses().Lister(),
+			secretInformer.Lister(),
+			kubeInformersForNamespaces.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps().Lister(),
+			kubeInformersForNamespaces.InformersFor(util.ConfigMapNamespace).Core().V1().ConfigMaps().Lister(),
+			configInformers.Config().V1().Infrastructures().Lister(),
+		),
+		getClusterParametersHook(
+			operatorClient,
+			kubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses().Lister(),
+			secretInformer.Lister(),
+			kubeInformersForNamespaces.InformersFor(util.OperatorNamespace).Core().V1().ConfigMaps().Lister(),
+			kubeInformersForNamespaces.InformersFor(util.ConfigMapNamespace).Core().V1().ConfigMaps().Lister(),
+			configInformers.Config().V1().Infrastructures().Lister(),
+		),
+		getParameterDriftHook(
+			operatorInformers.Operator().V1().ClusterCSIDrivers().Lister(),
+			kubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses().Lister(),
+			kubeInformersForNamespaces.InformersFor("").Core().V1().PersistentVolumes(),
+			controllerConfig.EventRecorder,
+		),
+	}
+
 	csiControllerSet := csicontrollerset.NewCSIControllerSet(
 		operatorClient,
 		controllerConfig.EventRecorder,
@@ -112,7 +153,6 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 			"rbac/configmap_and_secret_reader_provisioner_binding.yaml",
 			"rbac/main_resizer_binding.yaml",
 			"rbac/main_snapshotter_binding.yaml",
-			"configmap.yaml",
 			"csidriver.yaml",
 			"service.yaml",
 			"cabundle_cm.yaml",
@@ -139,6 +179,13 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		func() bool {
 			return false
 		},
+	).WithCredentialsRequestController(
+		"IBMBlockDriverCredentialsRequestController",
+		util.OperatorNamespace,
+		assets.ReadFile,
+		"credentials.yaml",
+		dynamicClient,
+		operatorInformers,
 	).WithCSIConfigObserverController(
 		"IBMBlockDriverCSIConfigObserverController",
 		configInformers,
@@ -156,6 +203,11 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		},
 		csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
 		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(util.OperatorNamespace, util.MetricsCertSecretName, secretInformer),
+		// Roll out the driver when the credentials in storage-secret-store are rotated.
+		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(util.OperatorNamespace, util.IBMCSIDriverSecretName, secretInformer),
+		// Restart the driver when its tunables change.
+		csidrivercontrollerservicecontroller.WithConfigMapHashAnnotationHook(util.OperatorNamespace, util.DriverConfigMapName, configMapInformer),
+		getTrustedProfileDeploymentHook(secretInformer.Lister(), configMapInformer.Lister()),
 		csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
 			util.OperatorNamespace,
 			util.TrustedCAConfigMap,
@@ -167,8 +219,14 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		"node.yaml",
 		kubeClient,
 		kubeInformersForNamespaces.InformersFor(util.OperatorNamespace),
-		[]factory.Informer{configMapInformer.Informer()},
+		[]factory.Informer{
+			secretInformer.Informer(),
+			configMapInformer.Informer(),
+		},
 		csidrivernodeservicecontroller.WithObservedProxyDaemonSetHook(),
+		csidrivernodeservicecontroller.WithSecretHashAnnotationHook(util.OperatorNamespace, util.IBMCSIDriverSecretName, secretInformer),
+		csidrivernodeservicecontroller.WithConfigMapHashAnnotationHook(util.OperatorNamespace, util.DriverConfigMapName, configMapInformer),
+		getTrustedProfileDaemonSetHook(secretInformer.Lister(), configMapInformer.Lister()),
 		csidrivernodeservicecontroller.WithCABundleDaemonSetHook(
 			util.OperatorNamespace,
 			util.TrustedCAConfigMap,
@@ -177,15 +235,11 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 	).WithStorageClassController(
 		"IBMBlockStorageClassController",
 		assets.ReadFile,
-		[]string{
-			"storageclass/vpc-block-10iopsTier-StorageClass.yaml",
-			"storageclass/vpc-block-5iopsTier-StorageClass.yaml",
-			"storageclass/vpc-block-custom-StorageClass.yaml",
-		},
+		storageClassFiles,
 		kubeClient,
 		kubeInformersForNamespaces.InformersFor(""),
 		operatorInformers,
-		getEncryptionKeyHook(operatorInformers.Operator().V1().ClusterCSIDrivers().Lister()),
+		storageClassHooks...,
 	)
 
 	if err != nil {
@@ -196,9 +250,77 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		operatorClient,
 		kubeClient,
 		kubeInformersForNamespaces,
+		configInformers,
+		operatorInformers,
 		util.Resync,
 		controllerConfig.EventRecorder)
 
+	driverConfigMap, err := assets.ReadFile("configmap.yaml")
+	if err != nil {
+		return err
+	}
+	tunablesController := tunables.NewTunablesController(
+		operatorClient,
+		kubeClient,
+		kubeInformersForNamespaces,
+		driverConfigMap,
+		util.Resync,
+		controllerConfig.EventRecorder)
+
+	// The StorageClasses of profiles offered only in some regions are managed separately
+	// from the ones above, which are installed everywhere.
+	profilesAsset, err := assets.ReadFile("storageclass_profiles.yaml")
+	if err != nil {
+		return err
+	}
+	profiles, err := storageclass.ParseProfiles(profilesAsset)
+	if err != nil {
+		return err
+	}
+	profileStorageClassController := storageclass.NewProfileStorageClassController(
+		operatorClient,
+		kubeClient,
+		kubeInformersForNamespaces,
+		configInformers,
+		operatorInformers,
+		assets.ReadFile,
+		profiles,
+		util.Resync,
+		controllerConfig.EventRecorder,
+		storageClassHooks...,
+	)
+
+	// Templates cannot replace the StorageClasses shipped with the operator.
+	reservedStorageClasses, err := storageclass.AssetNames(assets.ReadFile, storageClassFiles)
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		names, err := storageclass.AssetNames(assets.ReadFile, []string{profile.File})
+		if err != nil {
+			return err
+		}
+		reservedStorageClasses = append(reservedStorageClasses, names...)
+	}
+	templateStorageClassController := storageclass.NewTemplateStorageClassController(
+		operatorClient,
+		kubeClient,
+		kubeInformersForNamespaces,
+		operatorInformers,
+		reservedStorageClasses,
+		util.Resync,
+		controllerConfig.EventRecorder,
+		storageClassHooks...,
+	)
+
+	defaultStorageClassController := storageclass.NewDefaultStorageClassController(
+		operatorClient,
+		kubeClient,
+		kubeInformersForNamespaces,
+		util.Resync,
+		controllerConfig.EventRecorder,
+	)
+
 	serviceMonitorController := staticresourcecontroller.NewStaticResourceController(
 		"IBMBlockDriverServiceMonitorController",
 		assets.ReadFile,
@@ -219,6 +341,10 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 
 	klog.Info("Starting controllerset")
 	go secretSyncController.Run(ctx, 1)
+	go tunablesController.Run(ctx, 1)
+	go profileStorageClassController.Run(ctx, 1)
+	go templateStorageClassController.Run(ctx, 1)
+	go defaultStorageClassController.Run(ctx, 1)
 	go csiControllerSet.Run(ctx, 1)
 
 	<-ctx.Done()
@@ -0,0 +1,121 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	oplisterv1 "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	storagelisterv1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+)
+
+// getParameterDriftHook reports the StorageClasses of the operator whose immutable fields,
+// e.g. the encryption key parameters, differ from the existing StorageClass. The StorageClass
+// controllers then delete and re-create such StorageClasses, keeping the default StorageClass
+// annotation. The PersistentVolumes provisioned before keep the previous parameters, the event
+// says how many there are. The hook must run after the hooks that change the StorageClass.
+// The StorageClass controllers do not wait for the PersistentVolume informer, the hook fails
+// until it has synced, so that the count is not reported before.
+func getParameterDriftHook(
+	ccdLister oplisterv1.ClusterCSIDriverLister,
+	storageClassLister storagelisterv1.StorageClassLister,
+	pvInformer coreinformersv1.PersistentVolumeInformer,
+	recorder events.Recorder) csistorageclasscontroller.StorageClassHookFunc {
+	pvLister := pvInformer.Lister()
+	pvsSynced := pvInformer.Informer().HasSynced
+	// reported are the changes last reported by StorageClass name, so that a change is
+	// reported once even when the informers did not see the re-created StorageClass yet,
+	// or when applying it failed and is retried.
+	var lock sync.Mutex
+	reported := map[string]string{}
+	return func(_ *opv1.OperatorSpec, class *storagev1.StorageClass) error {
+		// Unmanaged and removed StorageClasses are not re-created.
+		ccd, err := ccdLister.Get(class.Provisioner)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && ccd.Spec.StorageClassState != "" && ccd.Spec.StorageClassState != opv1.ManagedStorageClass {
+			return nil
+		}
+
+		existing, err := storageClassLister.Get(class.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		changes := immutableChanges(existing, class)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if len(changes) == 0 {
+			delete(reported, class.Name)
+			return nil
+		}
+		summary := strings.Join(changes, ", ")
+		if reported[class.Name] == summary {
+			return nil
+		}
+		if !pvsSynced() {
+			return fmt.Errorf("StorageClass %s is re-created once the PersistentVolumes informer has synced", class.Name)
+		}
+
+		pvs, err := pvLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		inUse := 0
+		for _, pv := range pvs {
+			if pv.Spec.StorageClassName == class.Name {
+				inUse++
+			}
+		}
+		klog.V(2).Infof("StorageClass %s changed %s, %d PersistentVolumes use the previous parameters", class.Name, summary, inUse)
+		recorder.Warningf("StorageClassParametersChanged",
+			"StorageClass %s will be re-created with changed %s. %d existing PersistentVolumes were provisioned with the previous parameters and keep them",
+			class.Name, summary, inUse)
+		reported[class.Name] = summary
+		return nil
+	}
+}
+
+// immutableChanges returns the immutable fields and parameters of existing that required changes.
+func immutableChanges(existing, required *storagev1.StorageClass) []string {
+	var changes []string
+	keys := map[string]bool{}
+	for key := range existing.Parameters {
+		keys[key] = true
+	}
+	for key := range required.Parameters {
+		keys[key] = true
+	}
+	for key := range keys {
+		oldValue, oldOK := existing.Parameters[key]
+		newValue, newOK := required.Parameters[key]
+		if oldOK != newOK || oldValue != newValue {
+			changes = append(changes, fmt.Sprintf("parameter %s", key))
+		}
+	}
+	sort.Strings(changes)
+	if existing.Provisioner != required.Provisioner {
+		changes = append(changes, "provisioner")
+	}
+	if !equality.Semantic.DeepEqual(existing.ReclaimPolicy, required.ReclaimPolicy) && required.ReclaimPolicy != nil {
+		changes = append(changes, "reclaimPolicy")
+	}
+	if !equality.Semantic.DeepEqual(existing.VolumeBindingMode, required.VolumeBindingMode) && required.VolumeBindingMode != nil {
+		changes = append(changes, "volumeBindingMode")
+	}
+	return changes
+}
@@ -0,0 +1,147 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	oplisterv1 "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	storagelisterv1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func pv(name, storageClassName string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.PersistentVolumeSpec{StorageClassName: storageClassName},
+	}
+}
+
+func TestParameterDriftHook(t *testing.T) {
+	encrypted := withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true")
+	tests := []struct {
+		name              string
+		storageClassState opv1.StorageClassStateName
+		objects           []runtime.Object
+		inputSC           *storagev1.StorageClass
+		expectedMessage   string
+	}{
+		{
+			name:    "new StorageClass",
+			inputSC: encrypted,
+		},
+		{
+			name:    "same parameters",
+			objects: []runtime.Object{withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true")},
+			inputSC: encrypted,
+		},
+		{
+			name:            "encryption key set after install",
+			objects:         []runtime.Object{sc(), pv("pv-1", sc().Name), pv("pv-2", sc().Name), pv("pv-3", "other")},
+			inputSC:         encrypted,
+			expectedMessage: "StorageClass ibmc-vpc-block-10iops-tier will be re-created with changed parameter encrypted, parameter encryptionKey. 2 existing PersistentVolumes",
+		},
+		{
+			name:              "unmanaged StorageClasses",
+			storageClassState: opv1.UnmanagedStorageClass,
+			objects:           []runtime.Object{sc(), pv("pv-1", sc().Name)},
+			inputSC:           encrypted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storageClassIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			ccdIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			ccdIndexer.Add(&opv1.ClusterCSIDriver{
+				ObjectMeta: metav1.ObjectMeta{Name: provisionerName},
+				Spec:       opv1.ClusterCSIDriverSpec{StorageClassState: test.storageClassState},
+			})
+			var pvs []runtime.Object
+			for _, obj := range test.objects {
+				switch o := obj.(type) {
+				case *storagev1.StorageClass:
+					storageClassIndexer.Add(o)
+				case *corev1.PersistentVolume:
+					pvs = append(pvs, o)
+				}
+			}
+			// The PersistentVolumes are listed by a started informer, as in the operator.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(pvs...), 0)
+			recorder := events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now()))
+			hook := getParameterDriftHook(
+				oplisterv1.NewClusterCSIDriverLister(ccdIndexer),
+				storagelisterv1.NewStorageClassLister(storageClassIndexer),
+				informerFactory.Core().V1().PersistentVolumes(),
+				recorder,
+			)
+			informerFactory.Start(ctx.Done())
+			informerFactory.WaitForCacheSync(ctx.Done())
+
+			// The change is reported once, also when the StorageClass is applied again
+			// before the informer sees the re-created one.
+			for i := 0; i < 2; i++ {
+				if err := hook(nil, test.inputSC.DeepCopy()); err != nil {
+					t.Fatalf("hook() unexpected error: %v", err)
+				}
+			}
+			var messages []string
+			for _, event := range recorder.Events() {
+				if event.Reason == "StorageClassParametersChanged" {
+					messages = append(messages, event.Message)
+				}
+			}
+			switch {
+			case test.expectedMessage == "" && len(messages) > 0:
+				t.Errorf("unexpected events: %v", messages)
+			case test.expectedMessage != "" && (len(messages) != 1 || !strings.HasPrefix(messages[0], test.expectedMessage)):
+				t.Errorf("expected one event starting with %q, got %v", test.expectedMessage, messages)
+			}
+		})
+	}
+}
+
+func TestParameterDriftHookUnsyncedPersistentVolumes(t *testing.T) {
+	storageClassIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	storageClassIndexer.Add(sc())
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(pv("pv-1", sc().Name)), 0)
+	recorder := events.NewInMemoryRecorder("test", clocktesting.NewFakePassiveClock(time.Now()))
+	hook := getParameterDriftHook(
+		oplisterv1.NewClusterCSIDriverLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		storagelisterv1.NewStorageClassLister(storageClassIndexer),
+		informerFactory.Core().V1().PersistentVolumes(),
+		recorder,
+	)
+
+	// The change is not reported, nor applied, before the PersistentVolumes are known.
+	encrypted := withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true")
+	if err := hook(nil, encrypted.DeepCopy()); err == nil {
+		t.Fatalf("hook() expected an error before the PersistentVolumes informer has synced")
+	}
+	if len(recorder.Events()) != 0 {
+		t.Errorf("unexpected events before the PersistentVolumes informer has synced: %v", recorder.Events())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+	if err := hook(nil, encrypted.DeepCopy()); err != nil {
+		t.Fatalf("hook() unexpected error: %v", err)
+	}
+	if events := recorder.Events(); len(events) != 1 || !strings.Contains(events[0].Message, "1 existing PersistentVolumes") {
+		t.Errorf("expected one event with 1 existing PersistentVolume, got %v", events)
+	}
+}
@@ -1,42 +1,396 @@
 package operator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
 	opv1 "github.com/openshift/api/operator/v1"
+	configlisterv1 "github.com/openshift/client-go/config/listers/config/v1"
 	oplisterv1 "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/secret"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/ibmcloud"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
 	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
 	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	storagelisterv1 "k8s.io/client-go/listers/storage/v1"
 	"k8s.io/klog/v2"
 )
 
-// getEncryptionKeyHook checks for IBMCloudCSIDriverConfigSpec in the
-// ClusterCSIDriver object. If it contains EncryptionKeyCRN, it sets the
-// corresponding parameters in the StorageClass. This allows the admin to
-// specify a customer managed key to be used by default.
-func getEncryptionKeyHook(ccdLister oplisterv1.ClusterCSIDriverLister) csistorageclasscontroller.StorageClassHookFunc {
+const (
+	// encryptionKeyDegradedConditionType is True when the root key of a StorageClass
+	// cannot be used, the StorageClass then keeps the root key it was created with, if any.
+	encryptionKeyDegradedConditionType = "EncryptionKeyDegraded"
+	// storageClassTagsDegradedConditionType is True when tags of the operator config
+	// ConfigMap are not valid IBM Cloud tags, they are then not set on the StorageClasses.
+	storageClassTagsDegradedConditionType = "StorageClassTagsDegraded"
+	// storageClassTagsConfigKey is the key of the operator config ConfigMap with the
+	// comma-separated tags of the volumes of all StorageClasses of the operator.
+	storageClassTagsConfigKey = "storageClassTags"
+	// maxTagLength is the maximum length of an IBM Cloud tag.
+	maxTagLength = 128
+	// infrastructureName is the name of the cluster-scoped Infrastructure object.
+	infrastructureName = "cluster"
+)
+
+// tagPattern matches the characters allowed in IBM Cloud tags.
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9 _.:-]+$`)
+
+// getEncryptionKeyHook sets the root key of each StorageClass in its parameters, which
+// allows the admin to specify customer managed keys to be used by default. The key of a
+// StorageClass is the one of its name in the operator config ConfigMap, or else the
+// EncryptionKeyCRN of IBMCloudCSIDriverConfigSpec in the ClusterCSIDriver object.
+// The CRN must be the one of a Key Protect or Hyper Protect Crypto Services key in the
+// region and the account of the cluster, read from cloud-conf, the Infrastructure object
+// and the credentials override secret. Otherwise the StorageClass keeps the root key of
+// the existing StorageClass, or gets none when it is created, so that a wrong key is
+// not applied and the other StorageClasses are still reconciled. The
+// EncryptionKeyDegraded condition lists these StorageClasses and why. A missing
+// ClusterCSIDriver means there is no default key.
+func getEncryptionKeyHook(
+	operatorClient v1helpers.OperatorClient,
+	ccdLister oplisterv1.ClusterCSIDriverLister,
+	storageClassLister storagelisterv1.StorageClassLister,
+	secretLister corelisterv1.SecretLister,
+	operatorConfigMapLister corelisterv1.ConfigMapLister,
+	configMapLister corelisterv1.ConfigMapLister,
+	infraLister configlisterv1.InfrastructureLister) csistorageclasscontroller.StorageClassHookFunc {
+	// The hook is called by several StorageClass controllers, one StorageClass at a time,
+	// the condition is computed from the last result of every StorageClass.
+	keyErrors := &encryptionKeyErrors{
+		storageClassLister: storageClassLister,
+		errors:             map[string]*encryptionKeyError{},
+	}
 	return func(_ *opv1.OperatorSpec, class *storagev1.StorageClass) error {
 		ccd, err := ccdLister.Get(class.Provisioner)
 		if err != nil {
-			return err
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			klog.V(4).Infof("ClusterCSIDriver %s not found, using the %s of StorageClass %s only", class.Provisioner, encryptionKeyParameter, class.Name)
+			ccd = nil
 		}
-
-		driverConfig := ccd.Spec.DriverConfig
-		if driverConfig.DriverType != opv1.IBMCloudDriverType || driverConfig.IBMCloud == nil {
-			klog.V(4).Infof("No IBMCloudCSIDriverConfigSpec defined for %s", class.Provisioner)
-			return nil
+		operatorConfig, err := operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			operatorConfig = nil
 		}
 
-		crn := driverConfig.IBMCloud.EncryptionKeyCRN
+		crn, source := secret.StorageClassEncryptionKeyCRN(operatorConfig, ccd, class.Name)
 		if crn == "" {
 			klog.V(4).Infof("Not setting empty %s parameter in StorageClass %s", encryptionKeyParameter, class.Name)
-			return nil
+			return keyErrors.update(operatorClient, class.Name, nil)
+		}
+
+		if err := validateEncryptionKeyCRN(crn, configMapLister, operatorConfigMapLister, infraLister, secretLister); err != nil {
+			var keyErr *encryptionKeyError
+			if !errors.As(err, &keyErr) {
+				return err
+			}
+			keyErr.message = fmt.Sprintf("%s: %s", source, keyErr.message)
+			klog.V(2).Infof("Not setting %s parameter in StorageClass %s: %v", encryptionKeyParameter, class.Name, err)
+			if err := keepEncryptionKey(storageClassLister, class); err != nil {
+				return err
+			}
+			return keyErrors.update(operatorClient, class.Name, keyErr)
 		}
 
 		if class.Parameters == nil {
 			class.Parameters = map[string]string{}
 		}
-		klog.V(4).Infof("Setting %s = %s in StorageClass %s", encryptionKeyParameter, crn, class.Name)
+		klog.V(4).Infof("Setting %s = %s from %s in StorageClass %s", encryptionKeyParameter, crn, source, class.Name)
 		class.Parameters[encryptionKeyParameter] = crn
 		class.Parameters[encryptedParameter] = "true"
+		return keyErrors.update(operatorClient, class.Name, nil)
+	}
+}
+
+// keepEncryptionKey copies the encryption key parameters of the existing StorageClass to
+// class, so that a StorageClass is not re-created without its root key when the new one
+// cannot be used. A StorageClass that does not exist yet is created without a root key.
+func keepEncryptionKey(storageClassLister storagelisterv1.StorageClassLister, class *storagev1.StorageClass) error {
+	existing, err := storageClassLister.Get(class.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if existing.Parameters[encryptionKeyParameter] == "" {
 		return nil
 	}
+	if class.Parameters == nil {
+		class.Parameters = map[string]string{}
+	}
+	klog.V(2).Infof("Keeping %s = %s in StorageClass %s", encryptionKeyParameter, existing.Parameters[encryptionKeyParameter], class.Name)
+	for _, key := range []string{encryptionKeyParameter, encryptedParameter} {
+		if value, ok := existing.Parameters[key]; ok {
+			class.Parameters[key] = value
+		}
+	}
+	return nil
+}
+
+// encryptionKeyError is an EncryptionKeyCRN that cannot be used, with the reason of the condition.
+type encryptionKeyError struct {
+	reason  string
+	message string
+}
+
+func (e *encryptionKeyError) Error() string {
+	return e.message
+}
+
+// validateEncryptionKeyCRN checks that crn is a root key in the region and the account of
+// the cluster, the account of the credentials override secret when it sets one. A region
+// or an account that is not known yet is not checked.
+func validateEncryptionKeyCRN(
+	crn string,
+	configMapLister corelisterv1.ConfigMapLister,
+	operatorConfigMapLister corelisterv1.ConfigMapLister,
+	infraLister configlisterv1.InfrastructureLister,
+	secretLister corelisterv1.SecretLister) error {
+	key, err := ibmcloud.ParseEncryptionKeyCRN(crn)
+	if err != nil {
+		return &encryptionKeyError{reason: "InvalidCRN", message: err.Error()}
+	}
+	cloudConfig, err := secret.EffectiveCloudConfig(configMapLister, operatorConfigMapLister, infraLister, secretLister)
+	if err != nil {
+		return err
+	}
+	if region := cloudConfig.Provider.Region; region != "" && key.Location != region {
+		return &encryptionKeyError{
+			reason:  "RegionMismatch",
+			message: fmt.Sprintf("encryption key CRN %q is in region %s, the cluster is in region %s", crn, key.Location, region),
+		}
+	}
+	if accountID := cloudConfig.Provider.AccountID; accountID != "" && key.AccountID() != accountID {
+		return &encryptionKeyError{
+			reason:  "AccountMismatch",
+			message: fmt.Sprintf("encryption key CRN %q is in account %s, the cluster is in account %s", crn, key.AccountID(), accountID),
+		}
+	}
+	return nil
+}
+
+// encryptionKeyErrors are the unusable root keys of the StorageClasses by name.
+type encryptionKeyErrors struct {
+	lock               sync.Mutex
+	storageClassLister storagelisterv1.StorageClassLister
+	errors             map[string]*encryptionKeyError
+}
+
+// update records keyErr, nil when the root key of StorageClass className is usable or
+// not set, and sets the EncryptionKeyDegraded condition from all StorageClasses. The
+// errors of the other StorageClasses that no longer exist, e.g. of a removed template
+// or a disabled profile, are dropped.
+func (e *encryptionKeyErrors) update(operatorClient v1helpers.OperatorClient, className string, keyErr *encryptionKeyError) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if keyErr == nil {
+		delete(e.errors, className)
+	} else {
+		e.errors[className] = keyErr
+	}
+	for name := range e.errors {
+		if name == className {
+			continue
+		}
+		_, err := e.storageClassLister.Get(name)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.V(2).Infof("StorageClass %s no longer exists, not reporting its root key", name)
+		delete(e.errors, name)
+	}
+
+	condition := opv1.OperatorCondition{
+		Type:   encryptionKeyDegradedConditionType,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	var classNames []string
+	for name := range e.errors {
+		classNames = append(classNames, name)
+	}
+	sort.Strings(classNames)
+	var messages []string
+	for _, name := range classNames {
+		err := e.errors[name]
+		if condition.Status == opv1.ConditionFalse {
+			condition.Status = opv1.ConditionTrue
+			condition.Reason = err.reason
+		} else if condition.Reason != err.reason {
+			condition.Reason = "InvalidEncryptionKeys"
+		}
+		messages = append(messages, fmt.Sprintf("StorageClass %s: %s", name, err.message))
+	}
+	if len(messages) > 0 {
+		condition.Message = "Root keys are not used by the StorageClasses: " + strings.Join(messages, "; ")
+	}
+	_, _, err := v1helpers.UpdateStatus(context.TODO(), operatorClient, v1helpers.UpdateConditionFn(condition))
+	return err
+}
+
+// getClusterParametersHook fills the resourceGroup and region parameters of the new
+// StorageClasses left empty with the resource group and the region of the cluster, so
+// that volumes are created next to the cluster. It adds the infrastructure name of the
+// cluster and the tags of the operator config ConfigMap to the tags parameter, for cost
+// allocation. The resource group is the one of the cluster config, or the one resolved
+// by the SecretSync controller, and is left empty until it is resolved. The parameters
+// cannot be changed without re-creating the StorageClass: existing StorageClasses keep
+// the values they were created with, unless their template adds tags.
+func getClusterParametersHook(
+	operatorClient v1helpers.OperatorClient,
+	storageClassLister storagelisterv1.StorageClassLister,
+	secretLister corelisterv1.SecretLister,
+	operatorConfigMapLister corelisterv1.ConfigMapLister,
+	configMapLister corelisterv1.ConfigMapLister,
+	infraLister configlisterv1.InfrastructureLister) csistorageclasscontroller.StorageClassHookFunc {
+	return func(_ *opv1.OperatorSpec, class *storagev1.StorageClass) error {
+		if class.Parameters == nil {
+			class.Parameters = map[string]string{}
+		}
+		adminTags, invalid, err := storageClassTags(operatorConfigMapLister)
+		if err != nil {
+			return err
+		}
+
+		existing, err := storageClassLister.Get(class.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && hasTags(existing.Parameters[tagsParameter], class.Parameters[tagsParameter]) {
+			klog.V(4).Infof("Keeping the %s, %s and %s of existing StorageClass %s", resourceGroupParameter, regionParameter, tagsParameter, class.Name)
+			for _, key := range []string{resourceGroupParameter, regionParameter, tagsParameter} {
+				if value, ok := existing.Parameters[key]; ok && (class.Parameters[key] == "" || key == tagsParameter) {
+					class.Parameters[key] = value
+				}
+			}
+			return updateStorageClassTagsCondition(operatorClient, invalid)
+		}
+
+		cloudConfig, err := secret.EffectiveCloudConfig(configMapLister, operatorConfigMapLister, infraLister, secretLister)
+		if err != nil {
+			return err
+		}
+		resourceGroupID, err := secret.ClusterResourceGroupID(cloudConfig, operatorConfigMapLister)
+		if err != nil {
+			return err
+		}
+		if class.Parameters[resourceGroupParameter] == "" {
+			if resourceGroupID == "" {
+				klog.V(2).Infof("Resource group of the cluster not resolved yet, not setting %s in StorageClass %s", resourceGroupParameter, class.Name)
+			} else {
+				klog.V(4).Infof("Setting %s = %s in StorageClass %s", resourceGroupParameter, resourceGroupID, class.Name)
+				class.Parameters[resourceGroupParameter] = resourceGroupID
+			}
+		}
+		if region := cloudConfig.Provider.Region; class.Parameters[regionParameter] == "" && region != "" {
+			klog.V(4).Infof("Setting %s = %s in StorageClass %s", regionParameter, region, class.Name)
+			class.Parameters[regionParameter] = region
+		}
+
+		tags := splitTags(class.Parameters[tagsParameter])
+		infra, err := infraLister.Get(infrastructureName)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && infra.Status.InfrastructureName != "" {
+			tags = append(tags, infra.Status.InfrastructureName)
+		}
+		tags = append(tags, adminTags...)
+		if len(tags) > 0 {
+			class.Parameters[tagsParameter] = strings.Join(uniqueTags(tags), ",")
+			klog.V(4).Infof("Setting %s = %s in StorageClass %s", tagsParameter, class.Parameters[tagsParameter], class.Name)
+		}
+		return updateStorageClassTagsCondition(operatorClient, invalid)
+	}
+}
+
+// storageClassTags returns the valid and the invalid tags of the operator config ConfigMap.
+func storageClassTags(operatorConfigMapLister corelisterv1.ConfigMapLister) ([]string, []string, error) {
+	operatorConfig, err := operatorConfigMapLister.ConfigMaps(util.OperatorNamespace).Get(util.OperatorConfigMapName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var tags, invalid []string
+	for _, tag := range splitTags(operatorConfig.Data[storageClassTagsConfigKey]) {
+		if len(tag) > maxTagLength || !tagPattern.MatchString(tag) {
+			invalid = append(invalid, fmt.Sprintf("%q", tag))
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, invalid, nil
+}
+
+// splitTags returns the tags of a comma-separated list, without blanks.
+func splitTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// hasTags returns whether the comma-separated list of tags has all tags of required.
+func hasTags(list, required string) bool {
+	tags := map[string]bool{}
+	for _, tag := range splitTags(list) {
+		tags[strings.ToLower(tag)] = true
+	}
+	for _, tag := range splitTags(required) {
+		if !tags[strings.ToLower(tag)] {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueTags returns tags without duplicates, IBM Cloud tags are not case sensitive.
+func uniqueTags(tags []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, tag := range tags {
+		if key := strings.ToLower(tag); !seen[key] {
+			seen[key] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// updateStorageClassTagsCondition sets the StorageClassTagsDegraded condition from the invalid tags.
+func updateStorageClassTagsCondition(operatorClient v1helpers.OperatorClient, invalid []string) error {
+	condition := opv1.OperatorCondition{
+		Type:   storageClassTagsDegradedConditionType,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(invalid) > 0 {
+		condition.Status = opv1.ConditionTrue
+		condition.Reason = "InvalidTags"
+		condition.Message = fmt.Sprintf("Tags %s of configmap %s key %s are not set on the StorageClasses: IBM Cloud tags have at most %d letters, digits, spaces, _, ., : or -",
+			strings.Join(invalid, ", "), util.OperatorConfigMapName, storageClassTagsConfigKey, maxTagLength)
+	}
+	_, _, err := v1helpers.UpdateStatus(context.TODO(), operatorClient, v1helpers.UpdateConditionFn(condition))
+	return err
 }
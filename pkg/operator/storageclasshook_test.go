@@ -2,24 +2,36 @@ package operator
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	configv1 "github.com/openshift/api/config/v1"
 	opv1 "github.com/openshift/api/operator/v1"
+	configlisterv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	storagelisterv1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
 	provisionerName = "vpc.block.csi.ibm.io"
 	validCRNString  = "crn:v1:bluemix:public:kms:us-south:a/18240e5ed3f647cb96ca52dc92d9addd:d5e11420-3f0f-447a-92c0-13c978cf9096:key:c4a72f5b-9412-4ce8-80a4-2acb1987f3ba"
+	otherCRNString  = "crn:v1:bluemix:public:hs-crypto:us-south:a/18240e5ed3f647cb96ca52dc92d9addd:e6f22531-4a1a-458b-a3d1-24d089df0107:key:0b63c7a2-8e51-4f39-9a2c-5d7e1f3b4c6d"
 )
 
 func sc() *storagev1.StorageClass {
 	return &storagev1.StorageClass{
 		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ibmc-vpc-block-10iops-tier",
 			Annotations: nil,
 		},
 		Parameters: map[string]string{
@@ -37,13 +49,83 @@ func withParameters(sc *storagev1.StorageClass, keysAndValues ...string) *storag
 	return sc
 }
 
+func withEncryptionKeyCRN(crn string) *opv1.ClusterCSIDriver {
+	return &opv1.ClusterCSIDriver{
+		Spec: opv1.ClusterCSIDriverSpec{
+			DriverConfig: opv1.CSIDriverConfigSpec{
+				DriverType: opv1.IBMCloudDriverType,
+				IBMCloud: &opv1.IBMCloudCSIDriverConfigSpec{
+					EncryptionKeyCRN: crn,
+				},
+			},
+		},
+	}
+}
+
+func operatorConfig(keysAndValues ...string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.OperatorConfigMapName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string]string{},
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		cm.Data[keysAndValues[i]] = keysAndValues[i+1]
+	}
+	return cm
+}
+
+func credentialsOverride(keysAndValues ...string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.CredentialsOverrideSecretName,
+			Namespace: util.OperatorNamespace,
+		},
+		Data: map[string][]byte{"ibmcloud_api_key": []byte("api-key")},
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		secret.Data[keysAndValues[i]] = []byte(keysAndValues[i+1])
+	}
+	return secret
+}
+
+func storageClassLister(classes ...*storagev1.StorageClass) (storagelisterv1.StorageClassLister, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, class := range classes {
+		indexer.Add(class)
+	}
+	return storagelisterv1.NewStorageClassLister(indexer), indexer
+}
+
+func secretLister(secrets ...*corev1.Secret) corelisterv1.SecretLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, secret := range secrets {
+		indexer.Add(secret)
+	}
+	return corelisterv1.NewSecretLister(indexer)
+}
+
 func TestStorageClassHook(t *testing.T) {
+	cloudConf := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ConfigMapName,
+			Namespace: util.ConfigMapNamespace,
+		},
+		Data: map[string]string{"cloud.conf": "[provider]\naccountID = 18240e5ed3f647cb96ca52dc92d9addd\nregion = us-south\ng2ResourceGroupName = testresource\n"},
+	}
 	tests := []struct {
-		name        string
-		driver      *opv1.ClusterCSIDriver
-		inputSC     *storagev1.StorageClass
-		expectedSC  *storagev1.StorageClass
-		expectError bool
+		name              string
+		driver            *opv1.ClusterCSIDriver
+		cloudConf         *corev1.ConfigMap
+		operatorConfig    *corev1.ConfigMap
+		credentials       *corev1.Secret
+		existingSC        *storagev1.StorageClass
+		inputSC           *storagev1.StorageClass
+		expectedSC        *storagev1.StorageClass
+		expectError       bool
+		expectedCondition opv1.ConditionStatus
+		expectedReason    string
 	}{
 		{
 			name: "invalid provisioner",
@@ -105,13 +187,164 @@ func TestStorageClassHook(t *testing.T) {
 			inputSC:    sc(),
 			expectedSC: withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
 		},
+		{
+			name:              "missing ClusterCSIDriver",
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "encryption key in the cluster region and account",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "invalid CRN",
+			driver:            withEncryptionKeyCRN("crn:v1:bluemix:public:cloud-object-storage:us-south:a/18240e5ed3f647cb96ca52dc92d9addd:instance:key:key-id"),
+			cloudConf:         cloudConf,
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "InvalidCRN",
+		},
+		{
+			name:              "encryption key in another region",
+			driver:            withEncryptionKeyCRN(strings.Replace(validCRNString, "us-south", "eu-de", 1)),
+			cloudConf:         cloudConf,
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "RegionMismatch",
+		},
+		{
+			name:              "encryption key in another account",
+			driver:            withEncryptionKeyCRN(strings.Replace(validCRNString, "a/18240e5ed3f647cb96ca52dc92d9addd", "a/otheraccount", 1)),
+			cloudConf:         cloudConf,
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "AccountMismatch",
+		},
+		{
+			name:              "invalid CRN keeps the existing key",
+			driver:            withEncryptionKeyCRN("my-key"),
+			cloudConf:         cloudConf,
+			existingSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "InvalidCRN",
+		},
+		{
+			name:              "encryption key in the account of the credentials override",
+			driver:            withEncryptionKeyCRN(strings.Replace(validCRNString, "a/18240e5ed3f647cb96ca52dc92d9addd", "a/otheraccount", 1)),
+			cloudConf:         cloudConf,
+			credentials:       credentialsOverride("accountID", "otheraccount"),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, strings.Replace(validCRNString, "a/18240e5ed3f647cb96ca52dc92d9addd", "a/otheraccount", 1), encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "encryption key in the cluster account with a credentials override of another account",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			credentials:       credentialsOverride("accountID", "otheraccount"),
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "AccountMismatch",
+		},
+		{
+			name:              "StorageClass key replaces the ClusterCSIDriver one",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-10iops-tier", otherCRNString),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, otherCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "StorageClass key without ClusterCSIDriver",
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-10iops-tier", otherCRNString),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, otherCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "key of another StorageClass",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-5iops-tier", otherCRNString),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "empty StorageClass key opts out",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-10iops-tier", ""),
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "invalid StorageClass key",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-10iops-tier", "my-key"),
+			inputSC:           sc(),
+			expectedSC:        sc(),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "InvalidCRN",
+		},
+		{
+			name:              "invalid StorageClass key keeps the existing key",
+			driver:            withEncryptionKeyCRN(validCRNString),
+			cloudConf:         cloudConf,
+			operatorConfig:    operatorConfig("encryptionKeyCRN.ibmc-vpc-block-10iops-tier", "my-key"),
+			existingSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), encryptionKeyParameter, validCRNString, encryptedParameter, "true"),
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "InvalidCRN",
+		},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			ccdLister := &fakeCCDLister{test.driver}
-			hook := getEncryptionKeyHook(ccdLister)
+			configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if test.cloudConf != nil {
+				configMapIndexer.Add(test.cloudConf)
+			}
+			if test.operatorConfig != nil {
+				configMapIndexer.Add(test.operatorConfig)
+			}
+			var existing []*storagev1.StorageClass
+			if test.existingSC != nil {
+				existing = append(existing, test.existingSC)
+			}
+			classLister, _ := storageClassLister(existing...)
+			var secrets []*corev1.Secret
+			if test.credentials != nil {
+				secrets = append(secrets, test.credentials)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{}, &opv1.OperatorStatus{}, nil)
+			hook := getEncryptionKeyHook(
+				operatorClient,
+				ccdLister,
+				classLister,
+				secretLister(secrets...),
+				corelisterv1.NewConfigMapLister(configMapIndexer),
+				corelisterv1.NewConfigMapLister(configMapIndexer),
+				configlisterv1.NewInfrastructureLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+			)
 			err := hook(nil, test.inputSC)
 
 			if err != nil && !test.expectError {
@@ -123,10 +356,88 @@ func TestStorageClassHook(t *testing.T) {
 			if !equality.Semantic.DeepEqual(test.expectedSC, test.inputSC) {
 				t.Errorf("Unexpected StorageClass content:\n%s", cmp.Diff(test.expectedSC, test.inputSC))
 			}
+			if test.expectedCondition == "" {
+				return
+			}
+			_, status, _, _ := operatorClient.GetOperatorState()
+			condition := v1helpers.FindOperatorCondition(status.Conditions, encryptionKeyDegradedConditionType)
+			if condition == nil || condition.Status != test.expectedCondition || condition.Reason != test.expectedReason && test.expectedReason != "" {
+				t.Errorf("expected %s=%s with reason %q, got %+v", encryptionKeyDegradedConditionType, test.expectedCondition, test.expectedReason, condition)
+			}
 		})
 	}
 }
 
+func TestStorageClassHookCondition(t *testing.T) {
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	config := operatorConfig("encryptionKeyCRN.ibmc-vpc-block-5iops-tier", "my-key")
+	configMapIndexer.Add(config)
+	operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{}, &opv1.OperatorStatus{}, nil)
+	configMapLister := corelisterv1.NewConfigMapLister(configMapIndexer)
+	tier5 := sc()
+	tier5.Name = "ibmc-vpc-block-5iops-tier"
+	classLister, classIndexer := storageClassLister(sc(), tier5)
+	hook := getEncryptionKeyHook(
+		operatorClient,
+		&fakeCCDLister{withEncryptionKeyCRN(validCRNString)},
+		classLister,
+		secretLister(),
+		configMapLister,
+		configMapLister,
+		configlisterv1.NewInfrastructureLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+	)
+	degraded := func() *opv1.OperatorCondition {
+		_, status, _, _ := operatorClient.GetOperatorState()
+		return v1helpers.FindOperatorCondition(status.Conditions, encryptionKeyDegradedConditionType)
+	}
+
+	// The invalid key of one StorageClass is reported until it is fixed, whatever the
+	// order in which the StorageClasses are applied.
+	for _, class := range []*storagev1.StorageClass{tier5, sc()} {
+		if err := hook(nil, class); err != nil {
+			t.Fatalf("hook() unexpected error for %s: %v", class.Name, err)
+		}
+	}
+	if condition := degraded(); condition == nil || condition.Status != opv1.ConditionTrue || !strings.Contains(condition.Message, "StorageClass ibmc-vpc-block-5iops-tier") {
+		t.Errorf("expected %s=True for StorageClass ibmc-vpc-block-5iops-tier, got %+v", encryptionKeyDegradedConditionType, condition)
+	}
+
+	config = operatorConfig("encryptionKeyCRN.ibmc-vpc-block-5iops-tier", otherCRNString)
+	configMapIndexer.Update(config)
+	if err := hook(nil, tier5); err != nil {
+		t.Fatalf("hook() unexpected error: %v", err)
+	}
+	if condition := degraded(); condition == nil || condition.Status != opv1.ConditionFalse {
+		t.Errorf("expected %s=False, got %+v", encryptionKeyDegradedConditionType, condition)
+	}
+
+	// The key of a StorageClass that no longer exists, e.g. of a removed template, is no
+	// longer reported once the other StorageClasses are applied.
+	template := sc()
+	template.Name = "my-template"
+	classIndexer.Add(template)
+	configMapIndexer.Update(operatorConfig("encryptionKeyCRN.my-template", "my-key"))
+	if err := hook(nil, template); err != nil {
+		t.Fatalf("hook() unexpected error: %v", err)
+	}
+	if condition := degraded(); condition == nil || condition.Status != opv1.ConditionTrue || !strings.Contains(condition.Message, "StorageClass my-template") {
+		t.Errorf("expected %s=True for StorageClass my-template, got %+v", encryptionKeyDegradedConditionType, condition)
+	}
+	if err := hook(nil, sc()); err != nil {
+		t.Fatalf("hook() unexpected error: %v", err)
+	}
+	if condition := degraded(); condition == nil || condition.Status != opv1.ConditionTrue {
+		t.Errorf("expected %s=True while StorageClass my-template exists, got %+v", encryptionKeyDegradedConditionType, condition)
+	}
+	classIndexer.Delete(template)
+	if err := hook(nil, sc()); err != nil {
+		t.Fatalf("hook() unexpected error: %v", err)
+	}
+	if condition := degraded(); condition == nil || condition.Status != opv1.ConditionFalse {
+		t.Errorf("expected %s=False once StorageClass my-template is deleted, got %+v", encryptionKeyDegradedConditionType, condition)
+	}
+}
+
 type fakeCCDLister struct {
 	driver *opv1.ClusterCSIDriver
 }
@@ -136,8 +447,179 @@ func (f fakeCCDLister) List(selector labels.Selector) (ret []*opv1.ClusterCSIDri
 }
 
 func (f fakeCCDLister) Get(name string) (*opv1.ClusterCSIDriver, error) {
+	if f.driver == nil {
+		return nil, apierrors.NewNotFound(opv1.Resource("clustercsidrivers"), name)
+	}
 	if name != provisionerName {
 		return nil, fmt.Errorf("ClusterCSIDriver %q not found", name)
 	}
 	return f.driver, nil
 }
+
+func TestClusterParametersHook(t *testing.T) {
+	resourceGroupCache := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: util.ResourceGroupCacheConfigMapName, Namespace: util.OperatorNamespace},
+		Data: map[string]string{
+			"accountID":         "testaccount",
+			"resourceGroupName": "testresource",
+			"resourceGroupID":   "1234567890abcdef1234567890abcdef",
+		},
+	}
+	otherResourceGroupCache := resourceGroupCache.DeepCopy()
+	otherResourceGroupCache.Data["resourceGroupName"] = "otherresource"
+	cloudConf := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: util.ConfigMapName, Namespace: util.ConfigMapNamespace},
+		Data:       map[string]string{"cloud.conf": "[provider]\naccountID = testaccount\nregion = us-south\ng2ResourceGroupName = testresource\n"},
+	}
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "mycluster-x7k2p",
+			PlatformStatus: &configv1.PlatformStatus{
+				IBMCloud: &configv1.IBMCloudPlatformStatus{Location: "eu-de"},
+			},
+		},
+	}
+	tests := []struct {
+		name              string
+		configMaps        []*corev1.ConfigMap
+		infra             *configv1.Infrastructure
+		existingSC        *storagev1.StorageClass
+		inputSC           *storagev1.StorageClass
+		expectedSC        *storagev1.StorageClass
+		expectedCondition opv1.ConditionStatus
+	}{
+		{
+			name:              "resource group not resolved yet",
+			configMaps:        []*corev1.ConfigMap{cloudConf},
+			infra:             infra,
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "", regionParameter, "eu-de", tagsParameter, "mycluster-x7k2p"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "resource group cached for another name",
+			configMaps:        []*corev1.ConfigMap{otherResourceGroupCache, cloudConf},
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "", regionParameter, "us-south", tagsParameter, ""),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "StorageClass resource group without the resolved one",
+			inputSC:           withParameters(sc(), resourceGroupParameter, "other-group", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "other-group", regionParameter, "", tagsParameter, ""),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "resource group and cloud.conf region",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf},
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "us-south", tagsParameter, ""),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "resource group ID of the operator config",
+			configMaps:        []*corev1.ConfigMap{cloudConf, operatorConfig("resourceGroupID", "fedcba9876543210fedcba9876543210")},
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "fedcba9876543210fedcba9876543210", regionParameter, "us-south", tagsParameter, ""),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "Infrastructure region and cluster tag",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf},
+			infra:             infra,
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "mycluster-x7k2p"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "StorageClass values are kept",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf},
+			infra:             infra,
+			inputSC:           withParameters(sc(), resourceGroupParameter, "other-group", regionParameter, "eu-gb", tagsParameter, "team:storage, MyCluster-x7k2p"),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "other-group", regionParameter, "eu-gb", tagsParameter, "team:storage,MyCluster-x7k2p"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "admin tags",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf, operatorConfig(storageClassTagsConfigKey, "cost-center:1234, env:prod ,team:storage")},
+			infra:             infra,
+			inputSC:           withParameters(sc(), tagsParameter, "team:storage"),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "team:storage,mycluster-x7k2p,cost-center:1234,env:prod"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "invalid admin tags",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf, operatorConfig(storageClassTagsConfigKey, "env:prod,owner=me,"+strings.Repeat("a", maxTagLength+1))},
+			infra:             infra,
+			inputSC:           sc(),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "mycluster-x7k2p,env:prod"),
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:              "existing StorageClass keeps its values",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf, operatorConfig(storageClassTagsConfigKey, "env:prod")},
+			infra:             infra,
+			existingSC:        withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, ""),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "existing StorageClass keeps its tags",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf, operatorConfig(storageClassTagsConfigKey, "env:prod,owner=me")},
+			infra:             infra,
+			existingSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "team:storage,mycluster-x7k2p"),
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, "team:storage"),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "team:storage,mycluster-x7k2p"),
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:              "existing StorageClass with new template tags",
+			configMaps:        []*corev1.ConfigMap{resourceGroupCache, cloudConf},
+			infra:             infra,
+			existingSC:        withParameters(sc(), resourceGroupParameter, "", regionParameter, "eu-de", tagsParameter, "mycluster-x7k2p"),
+			inputSC:           withParameters(sc(), resourceGroupParameter, "", regionParameter, "", tagsParameter, "team:storage"),
+			expectedSC:        withParameters(sc(), resourceGroupParameter, "1234567890abcdef1234567890abcdef", regionParameter, "eu-de", tagsParameter, "team:storage,mycluster-x7k2p"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, cm := range test.configMaps {
+				configMapIndexer.Add(cm)
+			}
+			infraIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if test.infra != nil {
+				infraIndexer.Add(test.infra)
+			}
+			var existing []*storagev1.StorageClass
+			if test.existingSC != nil {
+				existing = append(existing, test.existingSC)
+			}
+			classLister, _ := storageClassLister(existing...)
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{}, &opv1.OperatorStatus{}, nil)
+			hook := getClusterParametersHook(
+				operatorClient,
+				classLister,
+				secretLister(),
+				corelisterv1.NewConfigMapLister(configMapIndexer),
+				corelisterv1.NewConfigMapLister(configMapIndexer),
+				configlisterv1.NewInfrastructureLister(infraIndexer),
+			)
+			if err := hook(nil, test.inputSC); err != nil {
+				t.Fatalf("got unexpected error: %s", err)
+			}
+			if !equality.Semantic.DeepEqual(test.expectedSC, test.inputSC) {
+				t.Errorf("Unexpected StorageClass content:\n%s", cmp.Diff(test.expectedSC, test.inputSC))
+			}
+			_, status, _, _ := operatorClient.GetOperatorState()
+			condition := v1helpers.FindOperatorCondition(status.Conditions, storageClassTagsDegradedConditionType)
+			if condition == nil || condition.Status != test.expectedCondition {
+				t.Errorf("expected %s=%s, got %+v", storageClassTagsDegradedConditionType, test.expectedCondition, condition)
+			}
+		})
+	}
+}
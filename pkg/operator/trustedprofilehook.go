@@ -0,0 +1,79 @@
+package operator
+
+import (
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/controller/secret"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	csiDriverContainerName                = "csi-driver"
+	computeResourceTokenVolumeName        = "compute-resource-token"
+	computeResourceTokenExpirationSeconds = 3600
+)
+
+// getTrustedProfileDeploymentHook mounts a projected service account token into the
+// csi-driver container when the driver authenticates with an IAM trusted profile.
+// The driver exchanges this token for a compute resource token, so no API key is
+// needed in the cluster.
+func getTrustedProfileDeploymentHook(secretLister corelisters.SecretLister, configMapLister corelisters.ConfigMapLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return mountComputeResourceToken(secretLister, configMapLister, deployment.Name, &deployment.Spec.Template.Spec)
+	}
+}
+
+// getTrustedProfileDaemonSetHook is getTrustedProfileDeploymentHook for the node plugin,
+// which reads the same storage-secret-store and needs the same token.
+func getTrustedProfileDaemonSetHook(secretLister corelisters.SecretLister, configMapLister corelisters.ConfigMapLister) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return mountComputeResourceToken(secretLister, configMapLister, daemonSet.Name, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+// mountComputeResourceToken adds the projected service account token to podSpec of
+// workload when a trusted profile is configured.
+func mountComputeResourceToken(secretLister corelisters.SecretLister, configMapLister corelisters.ConfigMapLister, workload string, podSpec *corev1.PodSpec) error {
+	profileID, err := secret.GetTrustedProfileID(secretLister, configMapLister)
+	if err != nil {
+		return err
+	}
+	if profileID == "" {
+		klog.V(4).Infof("No trusted profile configured, %s uses the API key", workload)
+		return nil
+	}
+
+	klog.V(4).Infof("Mounting compute resource token for trusted profile %s in %s", profileID, workload)
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: computeResourceTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          secret.ComputeResourceTokenAudience,
+							ExpirationSeconds: ptr.To[int64](computeResourceTokenExpirationSeconds),
+							Path:              secret.ComputeResourceTokenFile,
+						},
+					},
+				},
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name != csiDriverContainerName {
+			continue
+		}
+		podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, corev1.VolumeMount{
+			Name:      computeResourceTokenVolumeName,
+			MountPath: secret.ComputeResourceTokenDir,
+			ReadOnly:  true,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/assets"
+	"github.com/openshift/ibm-vpc-block-csi-driver-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const trustedProfileID = "Profile-9f1c3b8e-2d4a-4c55-8f3e-1a2b3c4d5e6f"
+
+func controllerDeployment(t *testing.T) *appsv1.Deployment {
+	manifest, err := assets.ReadFile("controller.yaml")
+	if err != nil {
+		t.Fatalf("failed to read controller.yaml: %v", err)
+	}
+	return resourceread.ReadDeploymentV1OrDie(manifest)
+}
+
+func findVolume(volumes []corev1.Volume, name string) *corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i]
+		}
+	}
+	return nil
+}
+
+func findVolumeMount(container *corev1.Container, name string) *corev1.VolumeMount {
+	for i := range container.VolumeMounts {
+		if container.VolumeMounts[i].Name == name {
+			return &container.VolumeMounts[i]
+		}
+	}
+	return nil
+}
+
+func TestTrustedProfileDeploymentHook(t *testing.T) {
+	tests := []struct {
+		name              string
+		secretData        map[string][]byte
+		configData        map[string]string
+		expectTokenVolume bool
+		expectError       bool
+	}{
+		{
+			name:       "API key",
+			secretData: map[string][]byte{"ibmcloud_api_key": []byte("testapikey")},
+		},
+		{
+			name:              "trusted profile in cloud-credential-operator secret",
+			secretData:        map[string][]byte{"ibmcloud_trusted_profile_id": []byte(trustedProfileID)},
+			expectTokenVolume: true,
+		},
+		{
+			name:              "trusted profile in operator config",
+			secretData:        map[string][]byte{"ibmcloud_api_key": []byte("testapikey")},
+			configData:        map[string]string{"trustedProfileID": trustedProfileID},
+			expectTokenVolume: true,
+		},
+		{
+			name:        "invalid trusted profile",
+			secretData:  map[string][]byte{"ibmcloud_trusted_profile_id": []byte("my-profile")},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			secretIndexer.Add(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: util.CloudCredentialSecretName, Namespace: util.OperatorNamespace},
+				Data:       test.secretData,
+			})
+			configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if test.configData != nil {
+				configMapIndexer.Add(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: util.OperatorConfigMapName, Namespace: util.OperatorNamespace},
+					Data:       test.configData,
+				})
+			}
+			hook := getTrustedProfileDeploymentHook(corelisters.NewSecretLister(secretIndexer), corelisters.NewConfigMapLister(configMapIndexer))
+
+			deployment := controllerDeployment(t)
+			original := deployment.DeepCopy()
+			err := hook(nil, deployment)
+			if err != nil && !test.expectError {
+				t.Fatalf("got unexpected error: %s", err)
+			}
+			if err == nil && test.expectError {
+				t.Fatalf("expected error, got none")
+			}
+			if !test.expectTokenVolume {
+				if diff := cmp.Diff(original, deployment); diff != "" {
+					t.Errorf("unexpected Deployment change:\n%s", diff)
+				}
+				return
+			}
+
+			checkComputeResourceTokenMount(t, deployment.Spec.Template.Spec)
+		})
+	}
+}
+
+func TestTrustedProfileDaemonSetHook(t *testing.T) {
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	secretIndexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: util.CloudCredentialSecretName, Namespace: util.OperatorNamespace},
+		Data:       map[string][]byte{"ibmcloud_trusted_profile_id": []byte(trustedProfileID)},
+	})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	hook := getTrustedProfileDaemonSetHook(corelisters.NewSecretLister(secretIndexer), corelisters.NewConfigMapLister(configMapIndexer))
+
+	manifest, err := assets.ReadFile("node.yaml")
+	if err != nil {
+		t.Fatalf("failed to read node.yaml: %v", err)
+	}
+	daemonSet := resourceread.ReadDaemonSetV1OrDie(manifest)
+	if err := hook(nil, daemonSet); err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	checkComputeResourceTokenMount(t, daemonSet.Spec.Template.Spec)
+}
+
+// checkComputeResourceTokenMount checks that podSpec mounts the projected service account
+// token into the csi-driver container only.
+func checkComputeResourceTokenMount(t *testing.T, podSpec corev1.PodSpec) {
+	t.Helper()
+	volume := findVolume(podSpec.Volumes, computeResourceTokenVolumeName)
+	if volume == nil || volume.Projected == nil || len(volume.Projected.Sources) != 1 || volume.Projected.Sources[0].ServiceAccountToken == nil {
+		t.Fatalf("expected projected service account token volume, got %+v", volume)
+	}
+	token := volume.Projected.Sources[0].ServiceAccountToken
+	if token.Audience != "iam" || token.Path != "vault-token" {
+		t.Errorf("unexpected service account token projection %+v", token)
+	}
+	for i := range podSpec.Containers {
+		mount := findVolumeMount(&podSpec.Containers[i], computeResourceTokenVolumeName)
+		if podSpec.Containers[i].Name != csiDriverContainerName {
+			if mount != nil {
+				t.Errorf("unexpected token mount in container %s", podSpec.Containers[i].Name)
+			}
+			continue
+		}
+		if mount == nil || mount.MountPath != "/var/run/secrets/tokens" || !mount.ReadOnly {
+			t.Errorf("expected read-only token mount in %s, got %+v", csiDriverContainerName, mount)
+		}
+	}
+}
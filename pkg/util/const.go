@@ -21,6 +21,33 @@ const (
 	// Name of secret created in operand namespace
 	IBMCSIDriverSecretName = "storage-secret-store"
 
+	// Name of the optional configmap in operator namespace with admin overrides of the operator configuration
+	OperatorConfigMapName = "ibm-vpc-block-csi-driver-operator-config"
+
+	// Name and namespace of the CredentialsRequest for the secret provided by cloud-credential-operator
+	CredentialsRequestName      = "ibm-vpc-block-csi-driver-operator"
+	CredentialsRequestNamespace = "openshift-cloud-credential-operator"
+
+	// Name of the optional secret in operator namespace with admin-supplied credentials. When it exists,
+	// it is used instead of the secret provided by cloud-credential-operator.
+	CredentialsOverrideSecretName = "ibm-vpc-block-csi-driver-credentials"
+
+	// Name of the configmap in operator namespace caching the resolved resource group ID.
+	// Deleting it forces the operator to look the resource group up again.
+	ResourceGroupCacheConfigMapName = "ibm-vpc-block-csi-driver-resource-group-cache"
+
+	// Name of the configmap in operator namespace with a summary of the driver configuration chosen by the operator.
+	// It never contains credentials.
+	StatusConfigMapName = "ibm-vpc-block-csi-driver-status"
+
+	// Name of the configmap in operator namespace with the environment of the driver, rendered from
+	// assets/configmap.yaml and the tunables in the operator config configmap
+	DriverConfigMapName = "ibm-vpc-block-csi-configmap"
+
+	// Name of the optional configmap in operator namespace with StorageClass templates of the admin,
+	// one StorageClass manifest per key
+	StorageClassTemplatesConfigMapName = "ibm-vpc-block-csi-driver-storageclass-templates"
+
 	// Name of the configmap with the injected trusted CA bundle
 	TrustedCAConfigMap = "ibm-vpc-block-csi-driver-trusted-ca-bundle"
 